@@ -24,11 +24,9 @@ func main() {
         log.Fatal(err)
     }
 
-    conf := mariadb.Config{
-        Uri: os.Getenv("DB_HOST"),
-        Username: os.Getenv("DB_USERNAME"),
-        Password: os.Getenv("DB_PASSWORD"),
-        Database: os.Getenv("DB_NAME"),
+    conf, err := mariadb.ParseDSN(os.Getenv("DB_DSN"))
+    if err != nil {
+        log.Fatal(err)
     }
     client, err := mariadb.Connect(conf, context.Background())
     if err != nil {
@@ -39,13 +37,11 @@ func main() {
     log.Println("insert rows")
     for i := 0; i < 11; i++ {
         query := fmt.Sprintf("INSERT INTO numbers(number) VALUES(%d)", i)
-        _, err = client.Query(query)
+        result, err := client.Exec(query)
         if err != nil {
             log.Fatal(err)
         }
-        lastInsertId := client.LastInsertId()
-        affectedRows := client.AffectedRows()
-        log.Printf("row %d was inserted. id=%d, affectedRows=%d\n", i, lastInsertId, affectedRows)
+        log.Printf("row %d was inserted. id=%d, affectedRows=%d\n", i, result.LastInsertId, result.AffectedRows)
     }
 
     // READ RECORD
@@ -62,11 +58,11 @@ func main() {
     }
 
     // DELETE ROWS
-    _, err = client.Query("DELETE FROM numbers WHERE id > 1")
+    result, err := client.Exec("DELETE FROM numbers WHERE id > 1")
     if err != nil {
         log.Fatal(err)
     }
-    log.Printf("%d row(s) were deleted\n", client.AffectedRows())
+    log.Printf("%d row(s) were deleted\n", result.AffectedRows)
 
     rows, err = client.Query("SELECT * FROM numbers")
     if err != nil {
@@ -0,0 +1,204 @@
+package mariadb
+
+import (
+    "context"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Options of COM_SET_OPTION
+const MYSQL_OPTION_MULTI_STATEMENTS_ON = 0
+const MYSQL_OPTION_MULTI_STATEMENTS_OFF = 1
+
+// Server statistics returned by COM_STATISTICS
+type ServerStatistics struct {
+    Uptime time.Duration
+    Threads int
+    Questions int
+    SlowQueries int
+    Opens int
+    FlushTables int
+    OpenTables int
+    QueriesPerSecond float64
+}
+
+// Thread running on server, see SHOW PROCESSLIST
+type ProcessInfo struct {
+    Id int
+    User string
+    Host string
+    Database string
+    Command string
+    Time int
+    State string
+    Info string
+    Progress float64
+}
+
+// Check that server is alive. Returns round-trip latency,
+// which includes time command waits in queue.
+func (c *Connection) Ping(ctx context.Context) (time.Duration, error) {
+    start := time.Now()
+    err := c.simpleCommand(ctx, createPingPacket())
+    return time.Since(start), err
+}
+
+// See https://mariadb.com/kb/en/com_statistics/
+func (c *Connection) Statistics(ctx context.Context) (*ServerStatistics, error) {
+    q := c.communicateContext(ctx, createCommandPacket(COM_STATISTICS))
+    defer finishResponse(ctx, q)
+    response, ok := next(ctx, q)
+    if !ok {
+        return nil, errUnexpectedEnd
+    }
+    if response.error != nil {
+        return nil, response.error
+    }
+    response.packet.skip(4)
+    return parseStatistics(string(response.packet.readBytesRest())), nil
+}
+
+// Parse string like 'Uptime: 1  Threads: 1  Questions: 2  Slow queries: 0
+// Opens: 1  Flush tables: 1  Open tables: 1  Queries per second avg: 0.1'
+func parseStatistics(s string) *ServerStatistics {
+    stats := &ServerStatistics{}
+    for _, field := range strings.Split(s, "  ") {
+        parts := strings.SplitN(field, ":", 2)
+        if len(parts) != 2 {
+            continue
+        }
+        value := strings.TrimSpace(parts[1])
+        number, _ := strconv.Atoi(value)
+        switch strings.TrimSpace(parts[0]) {
+        case "Uptime":
+            stats.Uptime = time.Duration(number) * time.Second
+        case "Threads":
+            stats.Threads = number
+        case "Questions":
+            stats.Questions = number
+        case "Slow queries":
+            stats.SlowQueries = number
+        case "Opens":
+            stats.Opens = number
+        case "Flush tables":
+            stats.FlushTables = number
+        case "Open tables":
+            stats.OpenTables = number
+        case "Queries per second avg":
+            stats.QueriesPerSecond, _ = strconv.ParseFloat(value, 64)
+        }
+    }
+    return stats
+}
+
+// List threads running on server, same as SHOW PROCESSLIST.
+// See https://mariadb.com/kb/en/com_process_info/
+func (c *Connection) ProcessList(ctx context.Context) ([]ProcessInfo, error) {
+    q := c.communicateContext(ctx, createCommandPacket(COM_PROCESS_INFO))
+    defer finishResponse(ctx, q)
+    response, ok := next(ctx, q)
+    if !ok {
+        return nil, errUnexpectedEnd
+    }
+    if response.error != nil {
+        return nil, response.error
+    }
+    packet := response.packet
+    packet.skip(4)
+    columns, err := c.readColumns(ctx, q, packet.readUIntLengthEncoded())
+    if err != nil {
+        return nil, err
+    }
+
+    processes := []ProcessInfo{}
+    for {
+        response, ok := next(ctx, q)
+        if !ok {
+            return nil, errUnexpectedEnd
+        }
+        if response.error != nil {
+            return nil, response.error
+        }
+        packet := response.packet
+        if packet.isEOF() {
+            return processes, nil
+        }
+
+        packet.skip(4)
+        process := ProcessInfo{}
+        for _, column := range columns {
+            value, _ := packet.readStringLengthEncodedNULLABLE()
+            switch strings.ToLower(column.name) {
+            case "id":
+                process.Id, _ = strconv.Atoi(value)
+            case "user":
+                process.User = value
+            case "host":
+                process.Host = value
+            case "db":
+                process.Database = value
+            case "command":
+                process.Command = value
+            case "time":
+                process.Time, _ = strconv.Atoi(value)
+            case "state":
+                process.State = value
+            case "info":
+                process.Info = value
+            case "progress":
+                process.Progress, _ = strconv.ParseFloat(value, 64)
+            }
+        }
+        processes = append(processes, process)
+    }
+}
+
+// Allow or deny several statements in one query.
+// See https://mariadb.com/kb/en/com_set_option/
+func (c *Connection) SetMultiStatements(ctx context.Context, enabled bool) error {
+    option := uint16(MYSQL_OPTION_MULTI_STATEMENTS_OFF)
+    if enabled {
+        option = MYSQL_OPTION_MULTI_STATEMENTS_ON
+    }
+    return c.simpleCommand(ctx, createSetOptionPacket(option))
+}
+
+// Make server write debug information to error log. Requires SUPER privilege.
+// See https://mariadb.com/kb/en/com_debug/
+func (c *Connection) Debug(ctx context.Context) error {
+    return c.simpleCommand(ctx, createCommandPacket(COM_DEBUG))
+}
+
+// Shut down server. Requires SHUTDOWN privilege.
+// See https://mariadb.com/kb/en/com_shutdown/
+func (c *Connection) Shutdown(ctx context.Context) error {
+    return c.simpleCommand(ctx, createShutdownPacket())
+}
+
+// Reset session without reauthentication: transaction is rolled back, locks are
+// released, user variables, temporary tables and prepared statements are dropped.
+// Session variables of Config.Params and Config.InitCommands are set again.
+// See https://mariadb.com/kb/en/com_reset_connection/
+func (c *Connection) Reset(ctx context.Context) error {
+    err := c.simpleCommand(ctx, createCommandPacket(COM_RESET_CONN))
+    if err != nil {
+        return err
+    }
+    c.generation.Add(1)
+    return c.setupSession(func(query string) error {
+        _, err := c.ExecContext(ctx, query)
+        return err
+    })
+}
+
+// Run command responding with OK or ERR packet
+func (c *Connection) simpleCommand(ctx context.Context, packet *Packet) error {
+    q := c.communicateContext(ctx, packet)
+    defer finishResponse(ctx, q)
+    response, ok := next(ctx, q)
+    if !ok {
+        return errUnexpectedEnd
+    }
+    return response.error
+}
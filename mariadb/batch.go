@@ -0,0 +1,100 @@
+package mariadb
+
+import (
+    "context"
+)
+
+// Independent statements sent back to back without waiting for
+// responses of each other, so batch costs single round trip.
+//
+//	batch := &mariadb.Batch{}
+//	batch.Queue("UPDATE numbers SET number = ? WHERE id = ?", 1, 1)
+//	batch.Queue("SELECT COUNT(*) AS count FROM numbers")
+//	results, err := client.SendBatch(ctx, batch)
+type Batch struct {
+    queries []string
+    args [][]interface{}
+}
+
+// Result of statement of batch
+type BatchResult struct {
+    // Rows of statement returning result set
+    Rows QueryResultRows
+    // Result of statement without result set
+    Result Result
+    Err error
+}
+
+// Add statement to batch, arguments replace ? placeholders
+func (b *Batch) Queue(query string, args ...interface{}) {
+    b.queries = append(b.queries, query)
+    b.args = append(b.args, args)
+}
+
+// Number of statements in batch
+func (b *Batch) Len() int {
+    return len(b.queries)
+}
+
+// Send all statements of batch and wait for their results. Statements
+// are independent, failed statement doesn't stop following ones.
+// Returned error is error of first failed statement.
+//
+// Context doesn't kill statements, KILL QUERY could hit other pipelined
+// command. When context is done, statements which aren't sent yet fail
+// with its error, statements already sent keep running on server and
+// their results are discarded. Use ExecContext for statements which
+// must be interrupted.
+func (c *Connection) SendBatch(ctx context.Context, b *Batch) ([]BatchResult, error) {
+    if c.heldByCaller() {
+        return nil, ErrTxActive
+    }
+    queue := make([]queuePacket, len(b.queries))
+    for i, query := range b.queries {
+        if len(b.args[i]) > 0 {
+            var err error
+            query, err = c.interpolate(query, b.args[i])
+            if err != nil {
+                return nil, err
+            }
+        }
+        queue[i] = createQueuePacket(createQueryPacket(query))
+        queue[i].ctx = ctx
+        queue[i].pipeline = true
+    }
+
+    // sent from single goroutine to keep order, results are
+    // read meanwhile so responses don't block the queue
+    go func() {
+        for _, q := range queue {
+            select {
+            case c.packetQueue <- q:
+            case <-c.ctx.Done():
+                q.c <- createQueuePacketError(c.closedError())
+                close(q.c)
+            }
+        }
+    }()
+
+    results := make([]BatchResult, len(queue))
+    var firstErr error
+    for i, q := range queue {
+        result := &results[i]
+        result.Rows, result.Result, result.Err = c.readResponse(ctx, q.c)
+        if result.Err != nil && firstErr == nil {
+            firstErr = result.Err
+        }
+    }
+    return results, firstErr
+}
+
+// Read whole response of query, rows of result set or result of statement
+func (c *Connection) readResponse(ctx context.Context, q chan queuePacket) (QueryResultRows, Result, error) {
+    rows, err := c.readRows(ctx, q)
+    if err != nil {
+        finishResponse(ctx, q)
+        return nil, Result{}, err
+    }
+    result, err := readAll(rows, nil)
+    return result, rows.result, err
+}
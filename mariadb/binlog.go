@@ -0,0 +1,78 @@
+package mariadb
+
+import (
+    "io"
+)
+
+// Server returns EOF packet instead of waiting for new events
+// when end of last binlog file is reached
+const BINLOG_DUMP_NON_BLOCK = 1
+// Server sends ANNOTATE_ROWS events with query text of row events
+const BINLOG_SEND_ANNOTATE_ROWS_EVENT = 2
+
+// Stream of raw binlog events.
+// While stream is open connection can't serve other commands.
+type BinlogStream struct {
+    conn *Connection
+    q chan queuePacket
+}
+
+// Register connection as replica with given server id.
+// Hostname and port are only reported in SHOW SLAVE HOSTS.
+func (c *Connection) RegisterReplica(serverId uint32, hostname string, port uint16) error {
+    q := c.communicate(createRegisterSlavePacket(serverId, hostname, port))
+    for response := range q {
+        if response.error != nil {
+            return response.error
+        }
+    }
+    return nil
+}
+
+// Request binlog events starting from file and position.
+// Empty file with position 4 starts from first available binlog file,
+// MariaDB starts from @slave_connect_state GTID position when it is set.
+func (c *Connection) BinlogDump(serverId uint32, file string, position uint32, flags uint16) *BinlogStream {
+    q := c.communicate(createBinlogDumpPacket(serverId, file, position, flags))
+    return &BinlogStream{conn: c, q: q}
+}
+
+// Next event, including event header but without leading OK byte.
+// Returns io.EOF when server ends stream.
+func (s *BinlogStream) Next() ([]byte, error) {
+    response, ok := <- s.q
+    if !ok {
+        return nil, io.EOF
+    }
+    if response.error != nil {
+        return nil, response.error
+    }
+    packet := response.packet
+    if packet.isEOF() {
+        return nil, io.EOF
+    }
+    return packet.payload[5:], nil
+}
+
+// Stop stream. Server doesn't support stopping binlog dump,
+// so connection is closed and can't be used anymore.
+func (s *BinlogStream) Close() {
+    s.conn.cancel()
+    s.conn.socket.Close()
+    go drainResponse(s.q)
+}
+
+// Forward binlog events until EOF packet or error
+func (c *Connection) recvBinlogStream(q *queuePacket) {
+    for {
+        packet, err := c.recv()
+        if err != nil {
+            q.c <- createQueuePacketError(err)
+            return
+        }
+        q.c <- createQueuePacket(packet)
+        if packet.isEOF() {
+            return
+        }
+    }
+}
@@ -0,0 +1,251 @@
+package mariadb
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Collation of connection or column
+type collation struct {
+    name string
+    charset string
+}
+
+// Collation used when Config.Collation is empty and server
+// doesn't send its default
+const defaultCollationName = "utf8mb4_general_ci"
+
+// Collations by id.
+// See https://mariadb.com/kb/en/supported-character-sets-and-collations/
+var collations = map[uint16]collation{
+    1: {"big5_chinese_ci", "big5"},
+    2: {"latin2_czech_cs", "latin2"},
+    3: {"dec8_swedish_ci", "dec8"},
+    4: {"cp850_general_ci", "cp850"},
+    5: {"latin1_german1_ci", "latin1"},
+    6: {"hp8_english_ci", "hp8"},
+    7: {"koi8r_general_ci", "koi8r"},
+    8: {"latin1_swedish_ci", "latin1"},
+    9: {"latin2_general_ci", "latin2"},
+    10: {"swe7_swedish_ci", "swe7"},
+    11: {"ascii_general_ci", "ascii"},
+    12: {"ujis_japanese_ci", "ujis"},
+    13: {"sjis_japanese_ci", "sjis"},
+    14: {"cp1251_bulgarian_ci", "cp1251"},
+    15: {"latin1_danish_ci", "latin1"},
+    16: {"hebrew_general_ci", "hebrew"},
+    18: {"tis620_thai_ci", "tis620"},
+    19: {"euckr_korean_ci", "euckr"},
+    20: {"latin7_estonian_cs", "latin7"},
+    21: {"latin2_hungarian_ci", "latin2"},
+    22: {"koi8u_general_ci", "koi8u"},
+    23: {"cp1251_ukrainian_ci", "cp1251"},
+    24: {"gb2312_chinese_ci", "gb2312"},
+    25: {"greek_general_ci", "greek"},
+    26: {"cp1250_general_ci", "cp1250"},
+    27: {"latin2_croatian_ci", "latin2"},
+    28: {"gbk_chinese_ci", "gbk"},
+    29: {"cp1257_lithuanian_ci", "cp1257"},
+    30: {"latin5_turkish_ci", "latin5"},
+    31: {"latin1_german2_ci", "latin1"},
+    32: {"armscii8_general_ci", "armscii8"},
+    33: {"utf8_general_ci", "utf8"},
+    34: {"cp1250_czech_cs", "cp1250"},
+    35: {"ucs2_general_ci", "ucs2"},
+    36: {"cp866_general_ci", "cp866"},
+    37: {"keybcs2_general_ci", "keybcs2"},
+    38: {"macce_general_ci", "macce"},
+    39: {"macroman_general_ci", "macroman"},
+    40: {"cp852_general_ci", "cp852"},
+    41: {"latin7_general_ci", "latin7"},
+    42: {"latin7_general_cs", "latin7"},
+    43: {"macce_bin", "macce"},
+    44: {"cp1250_croatian_ci", "cp1250"},
+    45: {"utf8mb4_general_ci", "utf8mb4"},
+    46: {"utf8mb4_bin", "utf8mb4"},
+    47: {"latin1_bin", "latin1"},
+    48: {"latin1_general_ci", "latin1"},
+    49: {"latin1_general_cs", "latin1"},
+    50: {"cp1251_bin", "cp1251"},
+    51: {"cp1251_general_ci", "cp1251"},
+    52: {"cp1251_general_cs", "cp1251"},
+    53: {"macroman_bin", "macroman"},
+    54: {"utf16_general_ci", "utf16"},
+    55: {"utf16_bin", "utf16"},
+    56: {"utf16le_general_ci", "utf16le"},
+    57: {"cp1256_general_ci", "cp1256"},
+    58: {"cp1257_bin", "cp1257"},
+    59: {"cp1257_general_ci", "cp1257"},
+    60: {"utf32_general_ci", "utf32"},
+    61: {"utf32_bin", "utf32"},
+    62: {"utf16le_bin", "utf16le"},
+    63: {"binary", "binary"},
+    64: {"armscii8_bin", "armscii8"},
+    65: {"ascii_bin", "ascii"},
+    66: {"cp1250_bin", "cp1250"},
+    67: {"cp1256_bin", "cp1256"},
+    68: {"cp866_bin", "cp866"},
+    69: {"dec8_bin", "dec8"},
+    70: {"greek_bin", "greek"},
+    71: {"hebrew_bin", "hebrew"},
+    72: {"hp8_bin", "hp8"},
+    73: {"keybcs2_bin", "keybcs2"},
+    74: {"koi8r_bin", "koi8r"},
+    75: {"koi8u_bin", "koi8u"},
+    77: {"latin2_bin", "latin2"},
+    78: {"latin5_bin", "latin5"},
+    79: {"latin7_bin", "latin7"},
+    80: {"cp850_bin", "cp850"},
+    81: {"cp852_bin", "cp852"},
+    82: {"swe7_bin", "swe7"},
+    83: {"utf8_bin", "utf8"},
+    84: {"big5_bin", "big5"},
+    85: {"euckr_bin", "euckr"},
+    86: {"gb2312_bin", "gb2312"},
+    87: {"gbk_bin", "gbk"},
+    88: {"sjis_bin", "sjis"},
+    89: {"tis620_bin", "tis620"},
+    90: {"ucs2_bin", "ucs2"},
+    91: {"ujis_bin", "ujis"},
+    92: {"geostd8_general_ci", "geostd8"},
+    93: {"geostd8_bin", "geostd8"},
+    94: {"latin1_spanish_ci", "latin1"},
+    95: {"cp932_japanese_ci", "cp932"},
+    96: {"cp932_bin", "cp932"},
+    97: {"eucjpms_japanese_ci", "eucjpms"},
+    98: {"eucjpms_bin", "eucjpms"},
+    99: {"cp1250_polish_ci", "cp1250"},
+    248: {"gb18030_chinese_ci", "gb18030"},
+    249: {"gb18030_bin", "gb18030"},
+    250: {"gb18030_unicode_520_ci", "gb18030"},
+    255: {"utf8mb4_0900_ai_ci", "utf8mb4"},
+}
+
+// Languages of unicode collations in order of their ids
+var unicodeCollationLanguages = []string{
+    "unicode", "icelandic", "latvian", "romanian", "slovenian", "polish",
+    "estonian", "spanish", "swedish", "turkish", "czech", "danish",
+    "lithuanian", "slovak", "spanish2", "roman", "persian", "esperanto",
+    "hungarian", "sinhala", "german2", "croatian", "unicode_520", "vietnamese",
+}
+
+// Collations by name
+var collationIds = map[string]uint16{}
+
+func init() {
+    // unicode collations of each charset have consecutive ids
+    for charset, first := range map[string]uint16{"utf16": 101, "ucs2": 128, "utf32": 160, "utf8": 192, "utf8mb4": 224} {
+        for i, language := range unicodeCollationLanguages {
+            collations[first + uint16(i)] = collation{charset + "_" + language + "_ci", charset}
+            if language == "unicode" || language == "unicode_520" {
+                collations[first + uint16(i) + 1024] = collation{charset + "_" + language + "_nopad_ci", charset}
+            }
+        }
+    }
+    // MariaDB NO PAD collations have ids of PAD SPACE ones plus 1024
+    for id, c := range collations {
+        if id > 99 {
+            continue
+        }
+        name := strings.Replace(c.name, "_bin", "_nopad_bin", 1)
+        if !strings.HasSuffix(c.name, "_bin") {
+            name = strings.TrimSuffix(c.name, "_ci") + "_nopad_ci"
+        }
+        if c.charset != "binary" && (strings.HasSuffix(c.name, "_bin") || defaultCollations[c.charset] == c.name) {
+            collations[id + 1024] = collation{name, c.charset}
+        }
+    }
+    for id, c := range collations {
+        collationIds[c.name] = id
+    }
+}
+
+// Default collation of charset, used by SET NAMES without COLLATE
+var defaultCollations = map[string]string{
+    "big5": "big5_chinese_ci",
+    "latin1": "latin1_swedish_ci",
+    "latin2": "latin2_general_ci",
+    "ascii": "ascii_general_ci",
+    "sjis": "sjis_japanese_ci",
+    "cp1251": "cp1251_general_ci",
+    "cp1250": "cp1250_general_ci",
+    "gbk": "gbk_chinese_ci",
+    "utf8": "utf8_general_ci",
+    "utf8mb3": "utf8_general_ci",
+    "ucs2": "ucs2_general_ci",
+    "utf8mb4": "utf8mb4_general_ci",
+    "utf16": "utf16_general_ci",
+    "utf16le": "utf16le_general_ci",
+    "utf32": "utf32_general_ci",
+    "cp932": "cp932_japanese_ci",
+    "gb18030": "gb18030_chinese_ci",
+    "binary": "binary",
+}
+
+// Find collation id by name. MariaDB 10.6 names utf8 collations utf8mb3.
+func lookupCollation(name string) (uint16, bool) {
+    name = strings.ToLower(name)
+    name = strings.Replace(name, "utf8mb3_", "utf8_", 1)
+    id, ok := collationIds[name]
+    return id, ok
+}
+
+// Charset of collation, empty string for unknown collation
+func collationCharset(id uint16) string {
+    return collations[id].charset
+}
+
+// Collation id which can be sent in handshake. Collations with id above 255
+// are replaced with default collation of the same charset or utf8mb4.
+func handshakeCollation(id uint16) uint8 {
+    if id <= 0xff {
+        return uint8(id)
+    }
+    if fallback, ok := lookupCollation(defaultCollations[collationCharset(id)]); ok && fallback <= 0xff {
+        return uint8(fallback)
+    }
+    id, _ = lookupCollation(defaultCollationName)
+    return uint8(id)
+}
+
+// Change charset and collation of connection with SET NAMES.
+// Default collation of charset is used when collation is empty.
+// See https://mariadb.com/kb/en/set-names/
+func (c *Connection) SetNames(charset string, collation string) error {
+    query, id, err := setNamesQuery(charset, collation)
+    if err != nil {
+        return err
+    }
+    _, err = c.Exec(query)
+    if err != nil {
+        return err
+    }
+    c.collation.Store(uint32(id))
+    return nil
+}
+
+func setNamesQuery(charset string, collation string) (string, uint16, error) {
+    name := collation
+    if name == "" {
+        name = defaultCollations[strings.ToLower(charset)]
+    }
+    id, ok := lookupCollation(name)
+    if !ok {
+        return "", 0, fmt.Errorf("unknown collation '%s' of charset '%s'", collation, charset)
+    }
+    normalized := strings.Replace(strings.ToLower(charset), "utf8mb3", "utf8", 1)
+    if collationCharset(id) != normalized {
+        return "", 0, fmt.Errorf("collation '%s' doesn't belong to charset '%s'", name, charset)
+    }
+
+    query := "SET NAMES " + normalized
+    if collation != "" {
+        query += " COLLATE " + collations[id].name
+    }
+    return query, id, nil
+}
+
+// Name of connection collation
+func (c *Connection) Collation() string {
+    return collations[uint16(c.collation.Load())].name
+}
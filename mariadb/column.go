@@ -0,0 +1,190 @@
+package mariadb
+
+import (
+    "reflect"
+    "time"
+)
+
+// Metadata of result set column.
+// See https://mariadb.com/kb/en/result-set-packets/#column-definition-packet
+type ColumnType struct {
+    Catalog string
+    Schema string
+    // Table alias used in query
+    Table string
+    OrgTable string
+    // Column alias used in query
+    Name string
+    OrgName string
+    Charset uint16
+    // Maximum length of column value
+    Length uint32
+    // One of MYSQL_TYPE_* constants
+    Type uint8
+    // Combination of FIELD_FLAG_* constants
+    Flags uint16
+    Decimals uint8
+}
+
+func newColumnType(column tableColumn) ColumnType {
+    return ColumnType{
+        Catalog: column.catalog,
+        Schema: column.schema,
+        Table: column.tableAlias,
+        OrgTable: column.table,
+        Name: column.name,
+        OrgName: column.orgName,
+        Charset: column.charset,
+        Length: column.maxSize,
+        Type: column.kind,
+        Flags: column.flag,
+        Decimals: column.decimals,
+    }
+}
+
+func columnTypes(columns []tableColumn) []ColumnType {
+    types := make([]ColumnType, len(columns))
+    for i, column := range columns {
+        types[i] = newColumnType(column)
+    }
+    return types
+}
+
+func (c ColumnType) Nullable() bool {
+    return c.Flags & FIELD_FLAG_NOT_NULL == 0
+}
+
+func (c ColumnType) Unsigned() bool {
+    return c.Flags & FIELD_FLAG_UNSIGNED != 0
+}
+
+func (c ColumnType) PrimaryKey() bool {
+    return c.Flags & FIELD_FLAG_PRIMARY_KEY != 0
+}
+
+func (c ColumnType) AutoIncrement() bool {
+    return c.Flags & FIELD_FLAG_AUTO_INCREMENT != 0
+}
+
+// Binary strings and blobs have binary charset
+func (c ColumnType) Binary() bool {
+    return c.Charset == binaryCharset
+}
+
+// SQL type name, e.g. "INT", "VARCHAR" or "BLOB"
+func (c ColumnType) DatabaseTypeName() string {
+    switch c.Type {
+    case MYSQL_TYPE_DECIMAL, MYSQL_TYPE_NEWDECIMAL:
+        return "DECIMAL"
+    case MYSQL_TYPE_TINY:
+        return "TINYINT"
+    case MYSQL_TYPE_SHORT:
+        return "SMALLINT"
+    case MYSQL_TYPE_LONG:
+        return "INT"
+    case MYSQL_TYPE_FLOAT:
+        return "FLOAT"
+    case MYSQL_TYPE_DOUBLE:
+        return "DOUBLE"
+    case MYSQL_TYPE_NULL:
+        return "NULL"
+    case MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_TIMESTAMP2:
+        return "TIMESTAMP"
+    case MYSQL_TYPE_LONGLONG:
+        return "BIGINT"
+    case MYSQL_TYPE_INT24:
+        return "MEDIUMINT"
+    case MYSQL_TYPE_DATE, MYSQL_TYPE_NEWDATE:
+        return "DATE"
+    case MYSQL_TYPE_TIME, MYSQL_TYPE_TIME2:
+        return "TIME"
+    case MYSQL_TYPE_DATETIME, MYSQL_TYPE_DATETIME2:
+        return "DATETIME"
+    case MYSQL_TYPE_YEAR:
+        return "YEAR"
+    case MYSQL_TYPE_BIT:
+        return "BIT"
+    case MYSQL_TYPE_JSON:
+        return "JSON"
+    case MYSQL_TYPE_ENUM:
+        return "ENUM"
+    case MYSQL_TYPE_SET:
+        return "SET"
+    case MYSQL_TYPE_GEOMETRY:
+        return "GEOMETRY"
+    case MYSQL_TYPE_TINY_BLOB:
+        return c.textOrBlob("TINY")
+    case MYSQL_TYPE_MEDIUM_BLOB:
+        return c.textOrBlob("MEDIUM")
+    case MYSQL_TYPE_LONG_BLOB:
+        return c.textOrBlob("LONG")
+    case MYSQL_TYPE_BLOB:
+        return c.textOrBlob("")
+    case MYSQL_TYPE_VARCHAR, MYSQL_TYPE_VAR_STRING:
+        if c.Binary() {
+            return "VARBINARY"
+        }
+        return "VARCHAR"
+    case MYSQL_TYPE_STRING:
+        // ENUM and SET columns are sent with MYSQL_TYPE_STRING type
+        if c.Flags & FIELD_FLAG_ENUM != 0 {
+            return "ENUM"
+        }
+        if c.Flags & FIELD_FLAG_SET != 0 {
+            return "SET"
+        }
+        if c.Binary() {
+            return "BINARY"
+        }
+        return "CHAR"
+    }
+    return ""
+}
+
+func (c ColumnType) textOrBlob(prefix string) string {
+    if c.Binary() {
+        return prefix + "BLOB"
+    }
+    return prefix + "TEXT"
+}
+
+var (
+    scanTypeInt = reflect.TypeOf(int(0))
+    scanTypeUint64 = reflect.TypeOf(uint64(0))
+    scanTypeFloat32 = reflect.TypeOf(float32(0))
+    scanTypeFloat64 = reflect.TypeOf(float64(0))
+    scanTypeTime = reflect.TypeOf(time.Time{})
+    scanTypeDuration = reflect.TypeOf(time.Duration(0))
+    scanTypeBytes = reflect.TypeOf([]byte(nil))
+    scanTypeString = reflect.TypeOf("")
+    scanTypeInterface = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// Go type of values returned for column. NULL values are always nil.
+func (c ColumnType) ScanType() reflect.Type {
+    switch c.Type {
+    case MYSQL_TYPE_NULL:
+        return scanTypeInterface
+    case MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT, MYSQL_TYPE_LONG, MYSQL_TYPE_INT24, MYSQL_TYPE_YEAR:
+        return scanTypeInt
+    case MYSQL_TYPE_LONGLONG:
+        if c.Unsigned() {
+            return scanTypeUint64
+        }
+        return scanTypeInt
+    case MYSQL_TYPE_FLOAT:
+        return scanTypeFloat32
+    case MYSQL_TYPE_DOUBLE:
+        return scanTypeFloat64
+    case MYSQL_TYPE_DATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP:
+        return scanTypeTime
+    case MYSQL_TYPE_TIME:
+        return scanTypeDuration
+    case MYSQL_TYPE_DECIMAL, MYSQL_TYPE_NEWDECIMAL:
+        return scanTypeString
+    }
+    if c.Binary() {
+        return scanTypeBytes
+    }
+    return scanTypeString
+}
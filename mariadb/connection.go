@@ -1,38 +1,118 @@
 package mariadb
 
 import (
+    "crypto/tls"
     "fmt"
+    "sort"
+    "strings"
+    "io"
     "net"
     "context"
+    "sync"
+    "sync/atomic"
     "time"
     _ "log"
-    "strconv"
     "github.com/vasflam/lab-mysql-connector/mariadb/capabilities"
 )
 
+var errUnexpectedEnd = fmt.Errorf("unexpected end of response")
+var errConnectionClosed = fmt.Errorf("connection is closed")
+
+// Time limit of KILL QUERY sent from side connection when
+// Config.ConnectTimeout isn't set
+const KillQueryTimeout = 5 * time.Second
+
 const COM_QUIT = 0x01
 const COM_INIT_DB = 0x02
 const COM_QUERY = 0x03
+const COM_SHUTDOWN = 0x08
+const COM_STATISTICS = 0x09
+const COM_PROCESS_INFO = 0x0a
+const COM_DEBUG = 0x0d
 const COM_PING = 0x0e
+const COM_BINLOG_DUMP = 0x12
+const COM_REGISTER_SLAVE = 0x15
+const COM_STMT_PREPARE = 0x16
+const COM_STMT_EXECUTE = 0x17
+const COM_STMT_SEND_LONG_DATA = 0x18
+const COM_STMT_CLOSE = 0x19
+const COM_STMT_RESET = 0x1a
+const COM_SET_OPTION = 0x1b
+const COM_STMT_FETCH = 0x1c
 const COM_RESET_CONN = 0x1f
 
 //  Connection configuration. 
-//  Uri in format 'host:port'
+//  Uri in format 'host:port' or path of unix socket. Several hosts
+//  are separated with comma, e.g. 'db1:3306,db2:3306', see Role.
 type Config struct {
     Uri string
+    // Role of server selected from several hosts: ROLE_ANY (default),
+    // ROLE_PRIMARY, ROLE_REPLICA or ROLE_PREFER_REPLICA. Primary is
+    // server without read_only and innodb_read_only. Role is checked
+    // on connect only. Connection to primary which became read only
+    // follows failover with AutoReconnect, without it statements fail
+    // with read only error.
+    Role string
+    // Try hosts in random order instead of order of Uri
+    RandomHosts bool
+    // Period host is tried last for, DefaultHostBlacklistTime when zero.
+    // Hosts are blacklisted on network errors and role mismatch, not on
+    // errors sent by server. Blacklist is shared by connections with
+    // the same hosts and role.
+    HostBlacklistTime time.Duration
+    // Network "tcp" or "unix". Detected by Uri when empty,
+    // paths starting with '/' are unix sockets.
+    Net string
+    // Custom dialer, e.g. for SSH tunnels or proxies.
+    // It gets host of Uri as address, Net is ignored.
+    Dialer func(ctx context.Context, addr string) (net.Conn, error)
     Username string
     Password string
     Database string
+    // Deprecated: use ConnectTimeout
     Timeout time.Duration
+    // Timeout of dial and handshake
+    ConnectTimeout time.Duration
+    // Timeout of reading single packet. Binlog stream fails when there are
+    // no events for this time, so heartbeat period must be shorter.
+    ReadTimeout time.Duration
+    // Timeout of writing single packet
+    WriteTimeout time.Duration
+    // Connection collation, e.g. utf8mb4_unicode_ci.
+    // Server default collation is used when empty.
+    Collation string
+    // Connection charset, default collation of charset is used
+    // when Collation is empty
+    Charset string
+    // Use TLS when not nil. ServerName is taken from host when empty.
+    TLS *tls.Config
+    // Attributes shown in performance_schema.session_connect_attrs
+    ConnectionAttributes map[string]string
+    // System variables set after connection, e.g. "sql_mode": "'ANSI'".
+    // Values are sent as is.
+    Params map[string]string
+    // Statements run after connection and reconnection
+    InitCommands []string
+    // Reconnect on next command after connection is broken,
+    // see TxLostError
+    AutoReconnect bool
+    // Return error from ScanStruct when column has no matching field
+    StrictScan bool
 }
 
 type connectionInfo struct {
+    connectionId    uint32
     serverVersion   string
     protocolVersion uint8
     serverCapabilities uint64
     clientCapabilities uint64
 }
 
+// Capabilities supported by both client and server
+func (i connectionInfo) capabilities() uint64 {
+    return i.clientCapabilities & i.serverCapabilities
+}
+
 // Describe database connection
 type Connection struct {
     ctx    context.Context
@@ -44,103 +124,371 @@ type Connection struct {
     info connectionInfo
     packetQueue chan queuePacket
     sequence uint8
-    lastInsertId int
-    affectedRows int
+    // result of last statement, see LastInsertId
+    lastResult atomic.Value
+    // server status flags of last response
+    status atomic.Uint32
+    // collation id of connection
+    collation atomic.Uint32
+    // I/O error which broke connection
+    failure atomic.Value
+    failOnce sync.Once
+    // current database, changes are tracked with session state
+    database atomic.Value
+    // I/O error which broke connection when AutoReconnect is enabled
+    broken error
+    brokenMu sync.Mutex
+    // sent commands waiting for response
+    pending chan queuePacket
+    // signalled when response is read
+    completed chan struct{}
+    // number of commands in pending and being read,
+    // accessed only by goroutine serving the queue
+    inflight int
+    // order of futures
+    async asyncQueue
+    // address of server from Config.Uri
+    host atomic.Value
+    // incremented on reconnect, statements of previous
+    // generation are prepared again
+    generation atomic.Uint64
+    // goroutine which started transaction holding connection, 0 without transaction
+    txGoroutine atomic.Uint64
 }
 
-// Establish connection with database
+// Establish connection with database. Connection is closed when parentCtx is done.
 func Connect(config Config, parentCtx context.Context) (*Connection, error) {
-    socket, err := net.Dial("tcp", config.Uri)
-    if err != nil {
-        return nil, err
-    }
+    return connect(config, parentCtx, parentCtx)
+}
+
+// Establish connection living until parentCtx is done. Dialing, handshake
+// and session setup are interrupted when dialCtx is done.
+func connect(config Config, parentCtx context.Context, dialCtx context.Context) (*Connection, error) {
     ctx, cancel := context.WithCancel(parentCtx)
     connection := &Connection{
         ctx: ctx,
         cancel: cancel,
         config: config,
-        socket: socket,
         ready: false,
         info: connectionInfo{},
         packetQueue: make(chan queuePacket),
+        pending: make(chan queuePacket, PipelineDepth),
+        completed: make(chan struct{}, PipelineDepth + 2),
     }
+    connection.database.Store(config.Database)
 
-    err = connection.init()
+    err := connection.open(dialCtx)
     if err != nil {
+        cancel()
         return nil, err
     }
 
     go connection.drainQueue()
+
+    err = interruptible(dialCtx, func() error {
+        return connection.setupSession(func(query string) error {
+            _, err := connection.Exec(query)
+            return err
+        })
+    }, func() {
+        connection.fail(dialCtx.Err())
+    })
+    if err != nil {
+        connection.Close()
+        return nil, err
+    }
     return connection, nil
 }
 
+// Run fn, interrupt is called when ctx is done before fn returns.
+// Returns error of ctx when fn was interrupted.
+func interruptible(ctx context.Context, fn func() error, interrupt func()) error {
+    if ctx.Done() == nil {
+        return fn()
+    }
+    stop := make(chan struct{})
+    interrupted := make(chan bool, 1)
+    go func() {
+        select {
+        case <-ctx.Done():
+            interrupt()
+            interrupted <- true
+        case <-stop:
+            interrupted <- false
+        }
+    }()
+    err := fn()
+    close(stop)
+    if <-interrupted {
+        return ctx.Err()
+    }
+    return err
+}
+
+func (config *Config) network() string {
+    if config.Net != "" {
+        return config.Net
+    }
+    if strings.HasPrefix(config.Uri, "/") {
+        return "unix"
+    }
+    return "tcp"
+}
+
+func (config *Config) dial(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error) {
+    if timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, timeout)
+        defer cancel()
+    }
+    if config.Dialer != nil {
+        return config.Dialer(ctx, addr)
+    }
+    dialer := net.Dialer{}
+    return dialer.DialContext(ctx, config.network(), addr)
+}
+
+// Upgrade connection to TLS after SSL request
+// See https://mariadb.com/kb/en/connection/#sslrequest-packet
+func (c *Connection) startTLS(request *handshakeRequest, response *Packet) error {
+    if request.capabilities & capabilities.SSL == 0 {
+        return fmt.Errorf("server doesn't support TLS")
+    }
+    err := c.send(createSSLRequestPacket(response))
+    if err != nil {
+        return err
+    }
+
+    config := c.config.TLS
+    if config.ServerName == "" && !config.InsecureSkipVerify {
+        config = config.Clone()
+        config.ServerName, _, _ = net.SplitHostPort(c.Host())
+    }
+    conn := tls.Client(c.socket, config)
+    err = conn.Handshake()
+    if err != nil {
+        return err
+    }
+    c.socket = conn
+    return nil
+}
+
+// Set collation which can't be sent in handshake, system variables
+// of Config.Params and run Config.InitCommands
+func (c *Connection) setupSession(exec func(query string) error) error {
+    // collations with id above 255 can't be sent in handshake
+    if collation := uint16(c.collation.Load()); collation > 0xff {
+        query, _, err := setNamesQuery(collationCharset(collation), collations[collation].name)
+        if err != nil {
+            return err
+        }
+        err = exec(query)
+        if err != nil {
+            return err
+        }
+    }
+
+    names := make([]string, 0, len(c.config.Params))
+    for name := range c.config.Params {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        for _, ch := range []byte(name) {
+            if !isNameChar(ch) && ch != '.' && ch != '@' {
+                return fmt.Errorf("invalid system variable name '%s'", name)
+            }
+        }
+        err := exec("SET " + name + " = " + c.config.Params[name])
+        if err != nil {
+            return err
+        }
+    }
+
+    for _, query := range c.config.InitCommands {
+        err := exec(query)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
 // Gracefuly close conenction
 func (c *Connection) Close() {
     <- c.communicate(createQuitPacket())
+    c.cancel()
+    c.socket.Close()
 }
 
-func (c *Connection) LastInsertId() int {
-    return c.lastInsertId
-}
-
-func (c *Connection) AffectedRows() int {
-    return c.affectedRows
+// Id of connection thread on server, same as CONNECTION_ID()
+func (c *Connection) ConnectionId() uint32 {
+    return c.info.connectionId
 }
 
 func (c *Connection) recv() (*Packet, error) {
-    header := make([]byte, 4)
-    n, err := c.socket.Read(header)
+    packet, err := c.recvPacket()
     if err != nil {
+        c.fail(err)
         return nil, err
     }
+    if packet.isERR() {
+        er := createErrorPacket(packet)
+        err := &MysqlError{Code: er.code(), Message: er.error()}
+        if c.config.Role == ROLE_PRIMARY && c.config.AutoReconnect && c.ready &&
+            (err.Code == ER_OPTION_PREVENTS_STATEMENT || err.Code == ER_READ_ONLY_MODE) {
+            // primary became read only after failover, next command
+            // reconnects to new primary
+            c.fail(err)
+        }
+        return nil, err
+    }
+    return packet, nil
+}
 
-    if n != 4 {
-        return nil, fmt.Errorf("Read less than requried")
+// Read packet from socket. Deadline is set per packet when
+// ReadTimeout is configured, handshake uses ConnectTimeout.
+func (c *Connection) recvPacket() (*Packet, error) {
+    if c.ready && c.config.ReadTimeout > 0 {
+        c.socket.SetReadDeadline(time.Now().Add(c.config.ReadTimeout))
+    }
+    header := make([]byte, 4)
+    _, err := io.ReadFull(c.socket, header)
+    if err != nil {
+        return nil, err
     }
 
     packet := &Packet{}
     packet.writeHeader(header)
     size := packet.readUInt24()
+    packet.resetPos()
     c.sequence = packet.getSequence()
 
     buf := make([]byte, size)
-    n, err = c.socket.Read(buf)
+    _, err = io.ReadFull(c.socket, buf)
     if err != nil {
-        return nil, fmt.Errorf("Failed to read packet payload")
+        return nil, fmt.Errorf("Failed to read packet payload: %w", err)
     }
     packet.writeBytes(buf)
     packet.direction = incomingPacket
 
-    if packet.isERR() {
-        er := createErrorPacket(packet)
-        return nil, fmt.Errorf("mysql error [%d]: %s", er.code(), er.error())
+    // payloads of 16MB and more are split into several packets
+    for size == MAX_PACKET_PAYLOAD {
+        _, err = io.ReadFull(c.socket, header)
+        if err != nil {
+            return nil, err
+        }
+        size = uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16
+        c.sequence = header[3]
+        buf = make([]byte, size)
+        _, err = io.ReadFull(c.socket, buf)
+        if err != nil {
+            return nil, fmt.Errorf("Failed to read packet payload: %w", err)
+        }
+        packet.writeBytes(buf)
     }
     return packet, nil
 }
 
 func (c *Connection) send(packet *Packet) error {
-    buf := packet.bytes()
+    if c.ready && c.config.WriteTimeout > 0 {
+        c.socket.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
+    }
+    buf := packet.wire()
     n, err := c.socket.Write(buf)
     if err != nil {
+        c.fail(err)
         return err
     }
 
     if n != len(buf) {
-        return fmt.Errorf("Sent less bytes than required")
+        err = fmt.Errorf("Sent less bytes than required")
+        c.fail(err)
+        return err
     }
 
     return nil
 }
 
+// Mark connection as broken after I/O error or timeout. Stream of packets
+// is out of sync, so connection is closed and all queued commands fail.
+// With AutoReconnect only socket is closed and next command reconnects.
+func (c *Connection) fail(err error) {
+    if c.config.AutoReconnect {
+        c.brokenMu.Lock()
+        defer c.brokenMu.Unlock()
+        if c.broken == nil {
+            c.broken = err
+            c.socket.Close()
+        }
+        return
+    }
+    c.failOnce.Do(func() {
+        c.failure.Store(err)
+        c.cancel()
+        c.socket.Close()
+    })
+}
+
+// Error returned for commands of closed connection
+func (c *Connection) closedError() error {
+    if err, ok := c.failure.Load().(error); ok {
+        return fmt.Errorf("connection is broken: %w", err)
+    }
+    return errConnectionClosed
+}
+
 // Sends packet to command queue
 func (c *Connection) communicate(packet *Packet) chan queuePacket {
+    return c.communicateContext(nil, packet)
+}
+
+// Sends packet to command queue. Command is skipped when context is done
+// before it is sent and killed when context is done while it runs.
+func (c *Connection) communicateContext(ctx context.Context, packet *Packet) chan queuePacket {
     q := createQueuePacket(packet)
+    q.ctx = ctx
+    return c.enqueue(q)
+}
+
+// Push command to queue, returns channel of its response
+func (c *Connection) enqueue(q queuePacket) chan queuePacket {
+    if c.heldByCaller() {
+        q.c <- createQueuePacketError(ErrTxActive)
+        close(q.c)
+        return q.c
+    }
     go func() {
-        c.packetQueue <- q
+        select {
+        case c.packetQueue <- q:
+        case <-c.ctx.Done():
+            q.c <- createQueuePacketError(c.closedError())
+            close(q.c)
+        }
     }()
     return q.c
 }
 
+// Wait for next packet of response or for context to be done
+func next(ctx context.Context, q chan queuePacket) (queuePacket, bool) {
+    select {
+    case response, ok := <- q:
+        return response, ok
+    case <-ctx.Done():
+        return createQueuePacketError(ctx.Err()), true
+    }
+}
+
+// Read rest of response, so connection stays in sync. Response of
+// interrupted command is drained in background to return immediately.
+func finishResponse(ctx context.Context, q chan queuePacket) {
+    if ctx.Err() != nil {
+        go drainResponse(q)
+    } else {
+        drainResponse(q)
+    }
+}
+
 /**
  * Do hanshake with server
  * See https://mariadb.com/kb/en/connection/
@@ -155,12 +503,42 @@ func (c *Connection) init() error {
 
     request := parseHandshakeRequest(packet)
     c.info = connectionInfo{
+        connectionId: request.connection,
         protocolVersion: request.protocolVersion,
         serverVersion: request.serverVersion,
         serverCapabilities: request.capabilities,
     }
 
-    response := createHandshakeResponsePacket(request, &c.config, &c.info)
+    collation := uint16(c.collation.Load())
+    if collation != 0 {
+        // collation of previous connection is kept on reconnect
+    } else if c.config.Collation != "" {
+        id, ok := lookupCollation(c.config.Collation)
+        if !ok {
+            return fmt.Errorf("unknown collation '%s'", c.config.Collation)
+        }
+        collation = id
+    } else if c.config.Charset != "" {
+        id, ok := lookupCollation(defaultCollations[strings.ToLower(c.config.Charset)])
+        if !ok {
+            return fmt.Errorf("unknown charset '%s'", c.config.Charset)
+        }
+        collation = id
+    } else {
+        collation = uint16(request.collation)
+    }
+    c.collation.Store(uint32(collation))
+    request.collation = handshakeCollation(collation)
+
+    config := c.config
+    config.Database = c.Database()
+    response := createHandshakeResponsePacket(request, &config, &c.info)
+    if c.config.TLS != nil {
+        err = c.startTLS(request, response)
+        if err != nil {
+            return err
+        }
+    }
     err = c.send(response)
     if err != nil {
         return err
@@ -180,152 +558,486 @@ func (c *Connection) init() error {
         return fmt.Errorf("handshake: Authentication Switch Request is unsupported yet")
     }
     
+    if packet.isOK() {
+        c.status.Store(uint32(parseOkPacket(packet, c.info.capabilities()).status))
+    }
     c.ready = true
     return nil
 }
 
-func (c *Connection) drainQueue() {
-    ticker := time.NewTicker(10 * time.Second)
-    recvPackets := func (c *Connection, q *queuePacket, initiator *Packet) {
-        for {
-            packet, err := c.recv()
-            if err != nil {
-                q.c <- queuePacket{error:err}
-                break
-            }
+// Read response for command and forward its packets to the waiter.
+// Reading stops exactly at the end of response, so waiter must
+// read every packet or call drainResponse.
+func (c *Connection) recvResponse(q *queuePacket) {
+    defer close(q.c)
+    command := q.packet.peekAt(4)
+    switch command {
+    case COM_QUIT, COM_STMT_SEND_LONG_DATA, COM_STMT_CLOSE:
+        // server doesn't respond to these commands
+        return
+    case COM_BINLOG_DUMP:
+        c.recvBinlogStream(q)
+        return
+    case COM_STMT_FETCH:
+        // rows of opened cursor without column definitions
+        if _, err := c.recvRows(q); err != nil {
+            q.c <- createQueuePacketError(err)
+        }
+        return
+    case COM_STATISTICS:
+        // response is single string packet
+        if err := c.recvPackets(q, 1); err != nil {
+            q.c <- createQueuePacketError(err)
+        }
+        return
+    }
 
-            if packet.isLOCALINFILE() {
-                err := fmt.Errorf("Unsupported packet LOCAL_INFILE")
-                q.c <- createQueuePacketError(err)
-                break
+    for {
+        packet, err := c.recv()
+        if err != nil {
+            q.c <- createQueuePacketError(err)
+            return
+        }
+
+        if packet.isLOCALINFILE() {
+            err := fmt.Errorf("Unsupported packet LOCAL_INFILE")
+            q.c <- createQueuePacketError(err)
+            return
+        }
+
+        // packet belongs to the waiter once forwarded,
+        // so everything needed is read before
+        var status uint16
+        if packet.isOK() && command == COM_STMT_PREPARE {
+            packet.skip(9)
+            columnCount := int(packet.readUInt16())
+            paramCount := int(packet.readUInt16())
+            packet.resetPos()
+            q.c <- createQueuePacket(packet)
+            err = c.recvPrepareResponse(q, columnCount, paramCount)
+        } else if packet.isOK() || packet.isEOF() {
+            ok := parseOkPacket(packet, c.info.capabilities())
+            status = ok.status
+            if ok.schema != "" {
+                c.database.Store(ok.schema)
             }
+            q.c <- createQueuePacket(packet)
+        } else {
+            packet.skip(4)
+            columnCount := packet.readUIntLengthEncoded()
+            packet.resetPos()
+            q.c <- createQueuePacket(packet)
+            status, err = c.recvResultSet(q, columnCount)
+        }
+
+        if err != nil {
+            q.c <- createQueuePacketError(err)
+            return
+        }
+        if command != COM_STMT_PREPARE {
+            c.status.Store(uint32(status))
+        }
+
+        if status & SERVER_MORE_RESULTS_EXISTS == 0 {
+            return
+        }
+    }
+}
+
+// Forward n packets to the waiter
+func (c *Connection) recvPackets(q *queuePacket, n int) error {
+    for i := 0; i < n; i++ {
+        packet, err := c.recv()
+        if err != nil {
+            return err
+        }
+        q.c <- createQueuePacket(packet)
+    }
+    return nil
+}
+
+// Receive EOF packet which follows column definitions when
+// DEPRECATE_EOF capability is not set
+func (c *Connection) recvIntermediateEOF() (*Packet, error) {
+    if c.info.capabilities() & capabilities.DEPRECATE_EOF != 0 {
+        return nil, nil
+    }
+    return c.recv()
+}
+
+// See https://mariadb.com/kb/en/com_stmt_prepare/#COM_STMT_PREPARE_OK
+func (c *Connection) recvPrepareResponse(q *queuePacket, columnCount, paramCount int) error {
+    for _, count := range []int{paramCount, columnCount} {
+        if count == 0 {
+            continue
+        }
+        if err := c.recvPackets(q, count); err != nil {
+            return err
+        }
+        if _, err := c.recvIntermediateEOF(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
 
+// Read column definitions and rows of result set. Returns server status
+// of terminating packet. EOF packet between column definitions and rows is
+// forwarded only when it terminates response (opened cursor).
+// See https://mariadb.com/kb/en/result-set-packets/
+func (c *Connection) recvResultSet(q *queuePacket, columnCount int) (uint16, error) {
+    err := c.recvPackets(q, columnCount)
+    if err != nil {
+        return 0, err
+    }
+
+    eof, err := c.recvIntermediateEOF()
+    if err != nil {
+        return 0, err
+    }
+    if eof != nil {
+        status := parseOkPacket(eof, c.info.capabilities()).status
+        if status & SERVER_STATUS_CURSOR_EXISTS != 0 {
+            q.c <- createQueuePacket(eof)
+            return status, nil
+        }
+    }
+    return c.recvRows(q)
+}
+
+// Forward rows until terminating EOF packet, returns its server status
+func (c *Connection) recvRows(q *queuePacket) (uint16, error) {
+    for {
+        packet, err := c.recv()
+        if err != nil {
+            return 0, err
+        }
+        if packet.isEOF() {
+            status := parseOkPacket(packet, c.info.capabilities()).status
             q.c <- createQueuePacket(packet)
+            return status, nil
+        }
+        q.c <- createQueuePacket(packet)
+    }
+}
+
+// Read all remaining packets of response
+func drainResponse(q chan queuePacket) {
+    for range q {}
+}
 
-            if packet.isOK() || packet.isEOF() {
-                break
+func (c *Connection) drainQueue() {
+    go c.readResponses()
+    defer close(c.pending)
+
+    ticker := time.NewTicker(10 * time.Second)
+    for {
+        select {
+        case q := <- c.packetQueue:
+            if q.session != nil {
+                if c.acquire(q) {
+                    c.serveSession(q.session, ticker)
+                }
+                continue
             }
+            c.serve(q)
+        case <-ticker.C:
+            c.ping()
+        case <-c.ctx.Done():
+            return
         }
-        close(q.c)
     }
+}
+
+// Read responses of sent commands in order they were sent
+func (c *Connection) readResponses() {
+    for q := range c.pending {
+        c.recvResponse(&q)
+        c.completed <- struct{}{}
+    }
+}
 
+// Serve commands of exclusive session until its channel is closed.
+// Commands sent to connection wait in queue meanwhile.
+func (c *Connection) serveSession(session chan queuePacket, ticker *time.Ticker) {
     for {
         select {
-        case q := <- c.packetQueue:
-            err := c.send(q.packet)
-            if err != nil {
-                q.c <- queuePacket{error: err}
-            } else {
-                recvPackets(c, &q, q.packet)
+        case q, ok := <- session:
+            if !ok {
+                return
             }
+            c.serve(q)
         case <-ticker.C:
-            q := c.communicate(createPingPacket())
-            for _ = range q {}
+            c.ping()
         case <-c.ctx.Done():
             return
         }
     }
 }
 
-// run mysql commands
-func (c *Connection) Query(query string) (QueryResultRows, error){
-    q := c.communicate(createQueryPacket(query))
-    // read first packet to get columns packets
-    response := <- q
-    if response.error != nil {
-        return nil, response.error
+// Reply to request of exclusive session, returns true when it is granted
+func (c *Connection) acquire(q queuePacket) bool {
+    defer close(q.c)
+    if q.ctx != nil && q.ctx.Err() != nil {
+        q.c <- createQueuePacketError(q.ctx.Err())
+        return false
     }
-    packet := response.packet
-    if packet.isOK() {
-        packet.skip(4)
-        packet.skip(1)
-        c.affectedRows = packet.readUIntLengthEncoded() // affected rows
-        c.lastInsertId = int(packet.readUIntLengthEncoded())
-        return nil, nil
+    if c.ctx.Err() != nil {
+        q.c <- createQueuePacketError(c.closedError())
+        return false
     }
+    return true
+}
 
-    columnCount := int(packet.peekAt(4))
-    rows := QueryResultRows{}
-    columns := []tableColumn{}
-    for i := 0; i < columnCount; i++ {
-        response := <- q
-        if response.error != nil {
-            return nil, response.error
+// Send command, its response is forwarded to the waiter by readResponses.
+// Commands are written without waiting for responses of previous ones,
+// except commands which can be killed and commands which take over
+// connection. They are sent when all responses are read, so KILL QUERY
+// can't interrupt other command.
+func (c *Connection) serve(q queuePacket) {
+    if q.ctx != nil && q.ctx.Err() != nil {
+        q.c <- createQueuePacketError(q.ctx.Err())
+        close(q.c)
+        return
+    }
+    if c.ctx.Err() != nil {
+        q.c <- createQueuePacketError(c.closedError())
+        close(q.c)
+        return
+    }
+    if c.brokenError() != nil {
+        // commands in flight fail with I/O error
+        c.waitResponses()
+        if q.packet.peekAt(4) == COM_QUIT {
+            close(q.c)
+            return
         }
-        packet := response.packet
-        packet.skip(4)
-        _ = packet.readStringLengthEncoded() // catalog
-        _ = packet.readStringLengthEncoded() // schema
-        _ = packet.readStringLengthEncoded() // tableAlias
-        _ = packet.readStringLengthEncoded() // table
-        columnAlias := packet.readStringLengthEncoded()
-        _ = packet.readStringLengthEncoded() // column
-        if c.info.clientCapabilities & capabilities.MARIADB_CLIENT_EXTENDED_TYPE_INFO != 0 {
-            count := packet.readUIntLengthEncoded()
-            for i = 0; i < count; i++ {
-                _ = packet.readUInt8() // type
-                _ = packet.readStringLengthEncoded() // value
-            }
+        if err := c.restore(); err != nil {
+            q.c <- createQueuePacketError(err)
+            close(q.c)
+            return
         }
-
-        fixedFields := packet.readUIntLengthEncoded()
-        charset := packet.readUInt16()
-        maxColSize := packet.readUInt32()
-        fieldType := packet.readUInt8()
-        fieldDetailFlag := packet.readUInt16()
-        decimals := packet.readUInt8()
-        unused := packet.readUInt16()
-        column := tableColumn{
-            columnAlias,
-            fixedFields,
-            charset,
-            maxColSize,
-            fieldType,
-            fieldDetailFlag,
-            decimals,
-            unused,
+    }
+    if q.statement && q.generation != c.generation.Load() {
+        // statement id belongs to previous connection
+        if q.packet.peekAt(4) != COM_STMT_CLOSE {
+            q.c <- createQueuePacketError(errStatementLost)
         }
-        columns = append(columns, column)
+        close(q.c)
+        return
     }
 
-    i := 0
+    exclusive := q.exclusive()
+    if exclusive {
+        c.waitResponses()
+    }
+    err := c.send(q.packet)
+    if err != nil {
+        q.c <- queuePacket{error: err}
+        close(q.c)
+        return
+    }
+    if !exclusive {
+        c.pushResponse(q)
+        return
+    }
+    stopWatch := c.watchCancel(q.ctx)
+    c.pushResponse(q)
+    c.waitResponses()
+    stopWatch()
+}
+
+// Pass sent command to readResponses
+func (c *Connection) pushResponse(q queuePacket) {
+    c.countResponses()
+    c.inflight++
+    c.pending <- q
+}
+
+// Account responses which are read. Called only by goroutine serving the queue.
+func (c *Connection) countResponses() {
     for {
-        response := <- q
+        select {
+        case <-c.completed:
+            c.inflight--
+        default:
+            return
+        }
+    }
+}
+
+// Wait until responses of all sent commands are read
+func (c *Connection) waitResponses() {
+    for c.inflight > 0 {
+        <-c.completed
+        c.inflight--
+    }
+}
+
+// Keep idle connection alive
+func (c *Connection) ping() {
+    c.countResponses()
+    if c.inflight > 0 || c.brokenError() != nil {
+        return
+    }
+    // sent directly, this goroutine serves the queue.
+    // Response fits into channel buffer, so it isn't read.
+    q := createQueuePacket(createPingPacket())
+    if c.send(q.packet) == nil {
+        c.pushResponse(q)
+    }
+}
+
+// Kill running command when context is done. Returned function stops
+// watching and waits for kill to finish, so next command can't be killed.
+func (c *Connection) watchCancel(ctx context.Context) func() {
+    if ctx == nil || ctx.Done() == nil {
+        return func() {}
+    }
+    done := make(chan struct{})
+    finished := make(chan struct{})
+    go func() {
+        defer close(finished)
+        select {
+        case <-done:
+        case <-ctx.Done():
+            c.killQuery()
+        }
+    }()
+    return func() {
+        close(done)
+        <-finished
+    }
+}
+
+// Interrupt running statement with KILL QUERY sent from side connection.
+// Server responds to interrupted statement with error. Side connection
+// only does handshake, session setup is skipped, and whole kill is
+// limited by ConnectTimeout or KillQueryTimeout when it isn't set.
+func (c *Connection) killQuery() error {
+    timeout := c.config.ConnectTimeout
+    if timeout == 0 {
+        timeout = KillQueryTimeout
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+    // query runs on this host only
+    socket, err := c.config.dial(ctx, c.Host(), timeout)
+    if err != nil {
+        return err
+    }
+    defer socket.Close()
+    socket.SetDeadline(time.Now().Add(timeout))
+
+    config := c.config
+    config.AutoReconnect = false
+    conn := &Connection{
+        ctx: ctx,
+        cancel: cancel,
+        config: config,
+        socket: socket,
+    }
+    conn.host.Store(c.Host())
+    conn.database.Store("")
+    err = conn.init()
+    if err != nil {
+        return err
+    }
+
+    q := createQueuePacket(createQueryPacket(fmt.Sprintf("KILL QUERY %d", c.info.connectionId)))
+    err = conn.send(q.packet)
+    if err != nil {
+        return err
+    }
+    go conn.recvResponse(&q)
+    rows, err := conn.readRows(ctx, q.c)
+    if err != nil {
+        drainResponse(q.c)
+        return err
+    }
+    rows.Close()
+    conn.send(createQuitPacket())
+    return nil
+}
+
+// Read column definitions of result set
+func (c *Connection) readColumns(ctx context.Context, q chan queuePacket, count int) ([]tableColumn, error) {
+    columns := []tableColumn{}
+    for i := 0; i < count; i++ {
+        response, ok := next(ctx, q)
+        if !ok {
+            return nil, errUnexpectedEnd
+        }
         if response.error != nil {
             return nil, response.error
         }
-        packet := response.packet
-
-        if packet.isEOF() || packet.isOK() {
-            break
-        }
-        packet.skip(4)
-        row := QueryResultRow{}
-        for i := 0; i < len(columns); i++ {
-            column := columns[i]
-            kind := column.kind
-
-            var value interface{}
-            if kind == MYSQL_TYPE_TINY ||
-                kind == MYSQL_TYPE_SHORT ||
-                kind == MYSQL_TYPE_LONG {
-                    str, isNULL := packet.readStringLengthEncodedNULLABLE()
-                    if isNULL {
-                        value = nil
-                    } else {
-                        var err error
-                        value, err = strconv.Atoi(str)
-                        if err != nil {
-                            return nil, err
-                        }
-                    }
-            }
-            row[column.name] = value
-        }
-        rows = append(rows, row)
-        i += 1
+        column := parseColumnDefinition(response.packet, c.info.clientCapabilities)
+        columns = append(columns, column)
+    }
+    return columns, nil
+}
+
+// Run query. Arguments replace ? placeholders, they are escaped
+// and interpolated into query on client side.
+//
+//	rows, err := client.Query("SELECT * FROM users WHERE name = ?", name)
+func (c *Connection) Query(query string, args ...interface{}) (QueryResultRows, error){
+    return c.QueryContext(context.Background(), query, args...)
+}
+
+// Run query. When context is done before query is finished
+// it is killed with KILL QUERY and context error is returned.
+// Whole result set is loaded into memory, use QueryRowsContext
+// to iterate over large results.
+func (c *Connection) QueryContext(ctx context.Context, query string, args ...interface{}) (QueryResultRows, error) {
+    return readAll(c.QueryRowsContext(ctx, query, args...))
+}
+
+// Load whole result set into memory
+func readAll(rows *Rows, err error) (QueryResultRows, error) {
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    if rows.columns == nil {
+        return nil, nil
     }
 
-    return rows, nil
+    result := QueryResultRows{}
+    for rows.Next() {
+        result = append(result, rows.Row().Map())
+    }
+    if rows.Err() != nil {
+        return nil, rows.Err()
+    }
+    return result, nil
 }
 
+// Run statement which doesn't return rows, e.g. INSERT or UPDATE.
+//
+//	result, err := client.Exec("DELETE FROM numbers WHERE id > ?", 1)
+//	log.Printf("%d row(s) were deleted", result.AffectedRows)
+func (c *Connection) Exec(query string, args ...interface{}) (Result, error) {
+    return c.ExecContext(context.Background(), query, args...)
+}
+
+// Run statement which doesn't return rows. When context is done before
+// statement is finished it is killed with KILL QUERY and context error is returned.
+// Rows returned by statement are discarded.
+func (c *Connection) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+    return readResult(c.QueryRowsContext(ctx, query, args...))
+}
+
+// Discard rows and return result of statement
+func readResult(rows *Rows, err error) (Result, error) {
+    if err != nil {
+        return Result{}, err
+    }
+    defer rows.Close()
+    for rows.Next() {
+    }
+    if rows.Err() != nil {
+        return Result{}, rows.Err()
+    }
+    return rows.result, nil
+}
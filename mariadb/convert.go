@@ -0,0 +1,218 @@
+package mariadb
+
+import (
+    "database/sql"
+    "fmt"
+    "math"
+    "reflect"
+    "strconv"
+    "time"
+)
+
+// Copy decoded column value src into dest pointer. Supported destinations are
+// pointers to strings, []byte, integers, floats, bool, time.Time, time.Duration,
+// interface{}, sql.Scanner implementations and pointers to them for NULL values.
+func convertAssign(dest, src interface{}) error {
+    switch d := dest.(type) {
+    case *interface{}:
+        *d = src
+        return nil
+    case sql.Scanner:
+        return d.Scan(scannerValue(src))
+    case *string:
+        switch s := src.(type) {
+        case string:
+            *d = s
+            return nil
+        case []byte:
+            *d = string(s)
+            return nil
+        case time.Time:
+            *d = s.Format("2006-01-02 15:04:05.999999")
+            return nil
+        case nil:
+            return fmt.Errorf("converting NULL to string is unsupported")
+        }
+        *d = fmt.Sprint(src)
+        return nil
+    case *[]byte:
+        switch s := src.(type) {
+        case []byte:
+            *d = append([]byte(nil), s...)
+            return nil
+        case nil:
+            *d = nil
+            return nil
+        }
+        var str string
+        err := convertAssign(&str, src)
+        if err != nil {
+            return err
+        }
+        *d = []byte(str)
+        return nil
+    case *time.Time:
+        switch s := src.(type) {
+        case time.Time:
+            *d = s
+            return nil
+        case string:
+            t, err := parseDateTime(s)
+            if err != nil {
+                return err
+            }
+            *d = t
+            return nil
+        }
+    case *time.Duration:
+        switch s := src.(type) {
+        case time.Duration:
+            *d = s
+            return nil
+        case string:
+            t, err := parseTime(s)
+            if err != nil {
+                return err
+            }
+            *d = t
+            return nil
+        }
+    case *bool:
+        switch s := src.(type) {
+        case bool:
+            *d = s
+            return nil
+        case int:
+            *d = s != 0
+            return nil
+        case string:
+            b, err := strconv.ParseBool(s)
+            if err != nil {
+                return err
+            }
+            *d = b
+            return nil
+        }
+    }
+
+    if src == nil {
+        return assignNULL(dest)
+    }
+    return assignReflect(dest, src)
+}
+
+// Set pointer to pointer destination to nil
+func assignNULL(dest interface{}) error {
+    dv := reflect.ValueOf(dest)
+    if dv.Kind() != reflect.Ptr || dv.IsNil() {
+        return fmt.Errorf("destination not a pointer")
+    }
+    dv = dv.Elem()
+    switch dv.Kind() {
+    case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+        dv.Set(reflect.Zero(dv.Type()))
+        return nil
+    }
+    return fmt.Errorf("converting NULL to %s is unsupported", dv.Kind())
+}
+
+// Convert numbers and strings into destination of any numeric,
+// string or pointer kind
+func assignReflect(dest, src interface{}) error {
+    dv := reflect.ValueOf(dest)
+    if dv.Kind() != reflect.Ptr || dv.IsNil() {
+        return fmt.Errorf("destination not a pointer")
+    }
+    dv = dv.Elem()
+    sv := reflect.ValueOf(src)
+    if sv.Type().AssignableTo(dv.Type()) {
+        dv.Set(sv)
+        return nil
+    }
+
+    str := asString(src)
+    switch dv.Kind() {
+    case reflect.Ptr:
+        value := reflect.New(dv.Type().Elem())
+        err := convertAssign(value.Interface(), src)
+        if err != nil {
+            return err
+        }
+        dv.Set(value)
+        return nil
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        i, err := strconv.ParseInt(str, 10, dv.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("converting %T %q to %s: %w", src, str, dv.Kind(), err)
+        }
+        dv.SetInt(i)
+        return nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        u, err := strconv.ParseUint(str, 10, dv.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("converting %T %q to %s: %w", src, str, dv.Kind(), err)
+        }
+        dv.SetUint(u)
+        return nil
+    case reflect.Float32, reflect.Float64:
+        f, err := strconv.ParseFloat(str, dv.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("converting %T %q to %s: %w", src, str, dv.Kind(), err)
+        }
+        dv.SetFloat(f)
+        return nil
+    case reflect.String:
+        dv.SetString(str)
+        return nil
+    }
+    return fmt.Errorf("unsupported Scan, storing %T into type %T", src, dest)
+}
+
+func asString(src interface{}) string {
+    switch s := src.(type) {
+    case string:
+        return s
+    case []byte:
+        return string(s)
+    case float32:
+        return strconv.FormatFloat(float64(s), 'g', -1, 32)
+    case float64:
+        return strconv.FormatFloat(s, 'g', -1, 64)
+    }
+    return fmt.Sprint(src)
+}
+
+// Values passed to sql.Scanner are limited to driver.Value types
+func scannerValue(src interface{}) interface{} {
+    switch s := src.(type) {
+    case int:
+        return int64(s)
+    case uint64:
+        if s > math.MaxInt64 {
+            return strconv.FormatUint(s, 10)
+        }
+        return int64(s)
+    case float32:
+        return float64(s)
+    case time.Duration:
+        return formatTime(s)
+    }
+    return src
+}
+
+// Format duration as TIME value [-]HH:MM:SS[.ffffff]
+func formatTime(d time.Duration) string {
+    sign := ""
+    if d < 0 {
+        sign = "-"
+        d = -d
+    }
+    hours := d / time.Hour
+    minutes := d % time.Hour / time.Minute
+    seconds := d % time.Minute / time.Second
+    micro := d % time.Second / time.Microsecond
+    if micro != 0 {
+        return fmt.Sprintf("%s%02d:%02d:%02d.%06d", sign, hours, minutes, seconds, micro)
+    }
+    return fmt.Sprintf("%s%02d:%02d:%02d", sign, hours, minutes, seconds)
+}
@@ -0,0 +1,131 @@
+package mariadb
+
+import (
+    "context"
+    "fmt"
+    "io"
+)
+
+// Read-only server-side cursor of prepared statement.
+// Server keeps result set and rows are fetched in batches
+// with COM_STMT_FETCH, so client holds only one batch at a time.
+// Statement can't be executed until cursor is closed.
+type Cursor struct {
+    stmt *Statement
+    columns []tableColumn
+    batchSize uint32
+    // rows sent with execute response when server didn't open cursor
+    pending QueryResultRows
+    done bool
+    closed bool
+}
+
+// Execute statement and open read-only cursor for its result set.
+// Fetch returns at most batchSize rows.
+func (s *Statement) OpenCursor(batchSize int, args ...interface{}) (*Cursor, error) {
+    if batchSize <= 0 {
+        return nil, fmt.Errorf("cursor batch size must be positive")
+    }
+    s.mu.Lock()
+    cursor, err := s.openCursor(uint32(batchSize), args)
+    if err != nil {
+        s.mu.Unlock()
+        return nil, err
+    }
+    return cursor, nil
+}
+
+func (s *Statement) openCursor(batchSize uint32, args []interface{}) (*Cursor, error) {
+    q, response, err := s.execute(CURSOR_TYPE_READ_ONLY, args)
+    if err != nil {
+        return nil, err
+    }
+    defer drainResponse(q)
+    if response.error != nil {
+        return nil, response.error
+    }
+    packet := response.packet
+    if packet.isOK() {
+        return nil, fmt.Errorf("statement doesn't return result set")
+    }
+
+    packet.skip(4)
+    columnCount := packet.readUIntLengthEncoded()
+    columns, err := s.conn.readColumns(context.Background(), q, columnCount)
+    if err != nil {
+        return nil, err
+    }
+
+    cursor := &Cursor{
+        stmt: s,
+        columns: columns,
+        batchSize: batchSize,
+    }
+    // Without SERVER_STATUS_CURSOR_EXISTS whole result set follows
+    rows, status, err := s.readRows(q, columns)
+    if err != nil {
+        return nil, err
+    }
+    if status & SERVER_STATUS_CURSOR_EXISTS == 0 {
+        cursor.pending = rows
+        cursor.done = true
+    }
+    return cursor, nil
+}
+
+// Metadata of cursor columns
+func (c *Cursor) ColumnTypes() []ColumnType {
+    return columnTypes(c.columns)
+}
+
+// Fetch next batch of rows. Returns io.EOF when all rows were fetched.
+// See https://mariadb.com/kb/en/com_stmt_fetch/
+func (c *Cursor) Fetch() (QueryResultRows, error) {
+    if c.closed {
+        return nil, fmt.Errorf("cursor is closed")
+    }
+    if c.pending != nil {
+        rows := c.pending
+        c.pending = nil
+        if len(rows) > 0 {
+            return rows, nil
+        }
+    }
+    if c.done {
+        return nil, io.EOF
+    }
+
+    q := c.stmt.communicate(createStmtFetchPacket(c.stmt.id, c.batchSize))
+    defer drainResponse(q)
+    rows, status, err := c.stmt.readRows(q, c.columns)
+    if err != nil {
+        return nil, err
+    }
+    if status & SERVER_STATUS_LAST_ROW_SENT != 0 || status & SERVER_STATUS_CURSOR_EXISTS == 0 {
+        c.done = true
+    }
+    if len(rows) == 0 {
+        return nil, io.EOF
+    }
+    return rows, nil
+}
+
+// Close cursor on server and release statement
+func (c *Cursor) Close() error {
+    if c.closed {
+        return nil
+    }
+    c.closed = true
+    defer c.stmt.mu.Unlock()
+    if c.done {
+        return nil
+    }
+    // COM_STMT_RESET closes opened cursor
+    q := c.stmt.communicate(createStmtResetPacket(c.stmt.id))
+    for response := range q {
+        if response.error != nil {
+            return response.error
+        }
+    }
+    return nil
+}
@@ -0,0 +1,292 @@
+package mariadb
+
+import (
+    "crypto/tls"
+    "fmt"
+    "net"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// TLS configs registered for tls parameter of DSN
+var tlsConfigs sync.Map
+
+// Register TLS config which can be used in DSN as tls=name
+func RegisterTLSConfig(name string, config *tls.Config) error {
+    switch strings.ToLower(name) {
+    case "true", "false", "skip-verify":
+        return fmt.Errorf("TLS config name '%s' is reserved", name)
+    }
+    tlsConfigs.Store(name, config)
+    return nil
+}
+
+// Parse DSN in format of go-sql-driver/mysql
+//
+//	[user[:password]@][net[(address)]]/dbname[?param1=value1&paramN=valueN]
+//
+// Net is tcp or unix, e.g. unix(/run/mysqld/mysqld.sock). Several tcp
+// hosts are separated with comma, e.g. tcp(db1:3306,db2:3306).
+//
+// Supported parameters are timeout, readTimeout, writeTimeout, charset,
+// collation, tls (true, false, skip-verify or registered config name),
+// connectionAttributes (k1:v1,k2:v2), role, randomHosts and hostBlacklistTime.
+// Other parameters are system variables set after connection. User and password may contain %-escaped characters.
+//
+//	config, err := mariadb.ParseDSN("user:pass@tcp(127.0.0.1:3306)/test?timeout=5s")
+func ParseDSN(dsn string) (Config, error) {
+    config := Config{}
+
+    // parameters can contain slash, they start after address
+    end := strings.LastIndex(dsn, ")") + 1
+    if question := strings.Index(dsn[end:], "?"); question >= 0 {
+        end += question
+    } else {
+        end = len(dsn)
+    }
+    slash := strings.LastIndex(dsn[:end], "/")
+    if slash < 0 {
+        return config, fmt.Errorf("invalid DSN: missing slash before database name")
+    }
+
+    // user:password@net(address)
+    prefix := dsn[:slash]
+    if at := strings.LastIndex(prefix, "@"); at >= 0 {
+        userinfo := prefix[:at]
+        prefix = prefix[at + 1:]
+        username, password, hasPassword := strings.Cut(userinfo, ":")
+        var err error
+        config.Username, err = url.PathUnescape(username)
+        if err != nil {
+            return config, fmt.Errorf("invalid DSN: invalid user: %w", err)
+        }
+        if hasPassword {
+            config.Password, err = url.PathUnescape(password)
+            if err != nil {
+                return config, fmt.Errorf("invalid DSN: invalid password: %w", err)
+            }
+        }
+    }
+    network, address := prefix, ""
+    if open := strings.Index(prefix, "("); open >= 0 {
+        if !strings.HasSuffix(prefix, ")") {
+            return config, fmt.Errorf("invalid DSN: address is not closed with ')'")
+        }
+        network, address = prefix[:open], prefix[open + 1:len(prefix) - 1]
+    }
+    if network == "" {
+        network = "tcp"
+    }
+    switch network {
+    case "tcp":
+        if address == "" {
+            address = "127.0.0.1:3306"
+        }
+        // several hosts are separated with comma
+        hosts := strings.Split(address, ",")
+        for i, host := range hosts {
+            if _, _, err := net.SplitHostPort(host); err != nil {
+                // IPv6 address without port is in brackets
+                host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+                hosts[i] = net.JoinHostPort(host, "3306")
+            }
+        }
+        address = strings.Join(hosts, ",")
+    case "unix":
+        if address == "" {
+            address = "/run/mysqld/mysqld.sock"
+        }
+    default:
+        return config, fmt.Errorf("invalid DSN: unsupported network '%s'", network)
+    }
+    config.Net = network
+    config.Uri = address
+
+    // database?params
+    database, query, _ := strings.Cut(dsn[slash + 1:], "?")
+    var err error
+    config.Database, err = url.PathUnescape(database)
+    if err != nil {
+        return config, fmt.Errorf("invalid DSN: invalid database name: %w", err)
+    }
+    params, err := url.ParseQuery(query)
+    if err != nil {
+        return config, fmt.Errorf("invalid DSN: %w", err)
+    }
+    for name, values := range params {
+        err := config.setParam(name, values[len(values) - 1])
+        if err != nil {
+            return config, fmt.Errorf("invalid DSN parameter %s: %w", name, err)
+        }
+    }
+    return config, nil
+}
+
+func (config *Config) setParam(name, value string) error {
+    var err error
+    switch name {
+    case "timeout":
+        config.ConnectTimeout, err = time.ParseDuration(value)
+    case "readTimeout":
+        config.ReadTimeout, err = time.ParseDuration(value)
+    case "writeTimeout":
+        config.WriteTimeout, err = time.ParseDuration(value)
+    case "charset":
+        if _, ok := defaultCollations[strings.ToLower(value)]; !ok {
+            return fmt.Errorf("unknown charset '%s'", value)
+        }
+        config.Charset = value
+    case "collation":
+        if _, ok := lookupCollation(value); !ok {
+            return fmt.Errorf("unknown collation '%s'", value)
+        }
+        config.Collation = value
+    case "role":
+        switch value {
+        case ROLE_ANY, ROLE_PRIMARY, ROLE_REPLICA, ROLE_PREFER_REPLICA:
+        default:
+            return fmt.Errorf("unknown role '%s'", value)
+        }
+        config.Role = value
+    case "randomHosts":
+        config.RandomHosts, err = strconv.ParseBool(value)
+    case "hostBlacklistTime":
+        config.HostBlacklistTime, err = time.ParseDuration(value)
+    case "tls":
+        config.TLS, err = parseTLSParam(value, config.Uri)
+    case "connectionAttributes", "attrs":
+        config.ConnectionAttributes = map[string]string{}
+        for _, pair := range strings.Split(value, ",") {
+            key, value, ok := strings.Cut(pair, ":")
+            if !ok || key == "" {
+                return fmt.Errorf("attribute '%s' isn't in format key:value", pair)
+            }
+            config.ConnectionAttributes[key] = value
+        }
+    default:
+        if config.Params == nil {
+            config.Params = map[string]string{}
+        }
+        config.Params[name] = value
+    }
+    return err
+}
+
+func parseTLSParam(value string, address string) (*tls.Config, error) {
+    switch strings.ToLower(value) {
+    case "true":
+        host, _, _ := net.SplitHostPort(address)
+        return &tls.Config{ServerName: host}, nil
+    case "false", "":
+        return nil, nil
+    case "skip-verify":
+        return &tls.Config{InsecureSkipVerify: true}, nil
+    }
+    if config, ok := tlsConfigs.Load(value); ok {
+        return config.(*tls.Config), nil
+    }
+    return nil, fmt.Errorf("unknown TLS config '%s'", value)
+}
+
+// Format config as DSN accepted by ParseDSN
+func FormatDSN(config Config) string {
+    var buf strings.Builder
+    if config.Username != "" || config.Password != "" {
+        buf.WriteString(escapeDSN(config.Username))
+        if config.Password != "" {
+            buf.WriteString(":")
+            buf.WriteString(escapeDSN(config.Password))
+        }
+        buf.WriteString("@")
+    }
+    buf.WriteString(config.network())
+    buf.WriteString("(")
+    buf.WriteString(config.Uri)
+    buf.WriteString(")/")
+    buf.WriteString(url.PathEscape(config.Database))
+
+    params := url.Values{}
+    timeout := config.ConnectTimeout
+    if timeout == 0 {
+        timeout = config.Timeout
+    }
+    if timeout > 0 {
+        params.Set("timeout", timeout.String())
+    }
+    if config.ReadTimeout > 0 {
+        params.Set("readTimeout", config.ReadTimeout.String())
+    }
+    if config.WriteTimeout > 0 {
+        params.Set("writeTimeout", config.WriteTimeout.String())
+    }
+    if config.Charset != "" {
+        params.Set("charset", config.Charset)
+    }
+    if config.Collation != "" {
+        params.Set("collation", config.Collation)
+    }
+    if config.TLS != nil {
+        params.Set("tls", formatTLSParam(config.TLS))
+    }
+    if config.Role != "" {
+        params.Set("role", config.Role)
+    }
+    if config.RandomHosts {
+        params.Set("randomHosts", "true")
+    }
+    if config.HostBlacklistTime != 0 {
+        params.Set("hostBlacklistTime", config.HostBlacklistTime.String())
+    }
+    if len(config.ConnectionAttributes) > 0 {
+        pairs := []string{}
+        for key, value := range config.ConnectionAttributes {
+            pairs = append(pairs, key + ":" + value)
+        }
+        sort.Strings(pairs)
+        params.Set("connectionAttributes", strings.Join(pairs, ","))
+    }
+    for name, value := range config.Params {
+        params.Set(name, value)
+    }
+    if len(params) > 0 {
+        buf.WriteString("?")
+        buf.WriteString(params.Encode())
+    }
+    return buf.String()
+}
+
+func formatTLSParam(config *tls.Config) string {
+    name := ""
+    tlsConfigs.Range(func(key, value interface{}) bool {
+        if value.(*tls.Config) == config {
+            name = key.(string)
+            return false
+        }
+        return true
+    })
+    if name != "" {
+        return name
+    }
+    if config.InsecureSkipVerify {
+        return "skip-verify"
+    }
+    return "true"
+}
+
+// Escape characters which have special meaning in DSN
+func escapeDSN(s string) string {
+    var buf strings.Builder
+    for i := 0; i < len(s); i++ {
+        switch ch := s[i]; ch {
+        case '%', '/', '@', ':', '?', '(', ')':
+            fmt.Fprintf(&buf, "%%%02X", ch)
+        default:
+            buf.WriteByte(ch)
+        }
+    }
+    return buf.String()
+}
@@ -0,0 +1,190 @@
+package mariadb
+
+import (
+    "crypto/tls"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestParseDSN(t *testing.T) {
+    tests := []struct {
+        dsn string
+        config Config
+    }{
+        {
+            "/",
+            Config{Net: "tcp", Uri: "127.0.0.1:3306"},
+        },
+        {
+            "user:pass@tcp(db:3307)/test",
+            Config{Net: "tcp", Uri: "db:3307", Username: "user", Password: "pass", Database: "test"},
+        },
+        {
+            "user@tcp(db)/",
+            Config{Net: "tcp", Uri: "db:3306", Username: "user"},
+        },
+        {
+            // unescaped @ and : in password
+            "user:p@ss:w@tcp(db1,db2:3307,[::1])/test",
+            Config{Net: "tcp", Uri: "db1:3306,db2:3307,[::1]:3306", Username: "user", Password: "p@ss:w", Database: "test"},
+        },
+        {
+            "us%3Aer:p%40s%2Fs%3Aw%25@tcp(db)/my%2Fdb",
+            Config{Net: "tcp", Uri: "db:3306", Username: "us:er", Password: "p@s/s:w%", Database: "my/db"},
+        },
+        {
+            "root@unix(/var/run/mysqld/mysqld.sock)/test?timeout=1m30s",
+            Config{Net: "unix", Uri: "/var/run/mysqld/mysqld.sock", Username: "root", Database: "test", ConnectTimeout: 90 * time.Second},
+        },
+        {
+            "unix()/test",
+            Config{Net: "unix", Uri: "/run/mysqld/mysqld.sock", Database: "test"},
+        },
+        {
+            "tcp(db)/test?readTimeout=500ms&writeTimeout=2s&charset=utf8mb4&collation=utf8mb4_unicode_ci",
+            Config{Net: "tcp", Uri: "db:3306", Database: "test", ReadTimeout: 500 * time.Millisecond,
+                WriteTimeout: 2 * time.Second, Charset: "utf8mb4", Collation: "utf8mb4_unicode_ci"},
+        },
+        {
+            "tcp(db1,db2)/test?role=primary&randomHosts=true&hostBlacklistTime=1m",
+            Config{Net: "tcp", Uri: "db1:3306,db2:3306", Database: "test", Role: ROLE_PRIMARY,
+                RandomHosts: true, HostBlacklistTime: time.Minute},
+        },
+        {
+            "tcp(db)/test?attrs=program:app,version:1.0&tls=false",
+            Config{Net: "tcp", Uri: "db:3306", Database: "test",
+                ConnectionAttributes: map[string]string{"program": "app", "version": "1.0"}},
+        },
+        {
+            "tcp(db)/test?connectionAttributes=a:&sql_mode=%27ANSI%27&time_zone='Europe/Moscow'",
+            Config{Net: "tcp", Uri: "db:3306", Database: "test", ConnectionAttributes: map[string]string{"a": ""},
+                Params: map[string]string{"sql_mode": "'ANSI'", "time_zone": "'Europe/Moscow'"}},
+        },
+    }
+    for _, test := range tests {
+        config, err := ParseDSN(test.dsn)
+        if err != nil {
+            t.Errorf("%s: %v", test.dsn, err)
+        } else if !reflect.DeepEqual(config, test.config) {
+            t.Errorf("%s: expected %+v, got %+v", test.dsn, test.config, config)
+        }
+    }
+}
+
+func TestParseDSNTLS(t *testing.T) {
+    custom := &tls.Config{ServerName: "custom"}
+    if err := RegisterTLSConfig("custom", custom); err != nil {
+        t.Fatal(err)
+    }
+    if err := RegisterTLSConfig("skip-verify", custom); err == nil {
+        t.Error("reserved TLS config name is registered")
+    }
+
+    config, err := ParseDSN("tcp(db.local:3306)/test?tls=true")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if config.TLS == nil || config.TLS.ServerName != "db.local" || config.TLS.InsecureSkipVerify {
+        t.Errorf("unexpected TLS config %+v", config.TLS)
+    }
+    config, err = ParseDSN("tcp(db)/test?tls=skip-verify")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if config.TLS == nil || !config.TLS.InsecureSkipVerify {
+        t.Errorf("unexpected TLS config %+v", config.TLS)
+    }
+    config, err = ParseDSN("tcp(db)/test?tls=custom")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if config.TLS != custom {
+        t.Error("registered TLS config isn't used")
+    }
+    if dsn := FormatDSN(config); dsn != "tcp(db:3306)/test?tls=custom" {
+        t.Errorf("unexpected DSN %s", dsn)
+    }
+}
+
+func TestParseDSNErrors(t *testing.T) {
+    tests := []struct {
+        dsn string
+        err string
+    }{
+        {"user@tcp(db)", "missing slash"},
+        {"tcp(db/test", "not closed"},
+        {"udp(db)/test", "unsupported network 'udp'"},
+        {"us%zzer@tcp(db)/test", "invalid user"},
+        {"user:p%zz@tcp(db)/test", "invalid password"},
+        {"tcp(db)/te%zzst", "invalid database name"},
+        {"tcp(db)/test?a=%zz", "invalid DSN"},
+        {"tcp(db)/test?timeout=5", "parameter timeout"},
+        {"tcp(db)/test?readTimeout=x", "parameter readTimeout"},
+        {"tcp(db)/test?charset=klingon", "unknown charset"},
+        {"tcp(db)/test?collation=klingon_ci", "unknown collation"},
+        {"tcp(db)/test?role=master", "unknown role"},
+        {"tcp(db)/test?randomHosts=maybe", "parameter randomHosts"},
+        {"tcp(db)/test?tls=missing", "unknown TLS config 'missing'"},
+        {"tcp(db)/test?attrs=program", "isn't in format key:value"},
+        {"tcp(db)/test?attrs=:app", "isn't in format key:value"},
+    }
+    for _, test := range tests {
+        _, err := ParseDSN(test.dsn)
+        if err == nil || !strings.Contains(err.Error(), test.err) {
+            t.Errorf("%s: expected error %q, got %v", test.dsn, test.err, err)
+        }
+    }
+}
+
+func TestFormatDSNRoundTrip(t *testing.T) {
+    tests := []struct {
+        config Config
+        dsn string
+    }{
+        {
+            Config{Net: "tcp", Uri: "db:3306", Username: "user", Password: "pass", Database: "test"},
+            "user:pass@tcp(db:3306)/test",
+        },
+        {
+            Config{Net: "tcp", Uri: "db:3306", Username: "u@s:er", Password: "p@s/s:w?%(x)", Database: "my db/x"},
+            "u%40s%3Aer:p%40s%2Fs%3Aw%3F%25%28x%29@tcp(db:3306)/my%20db%2Fx",
+        },
+        {
+            Config{Net: "unix", Uri: "/var/run/mysqld/mysqld.sock", Username: "root"},
+            "root@unix(/var/run/mysqld/mysqld.sock)/",
+        },
+        {
+            Config{Net: "tcp", Uri: "db1:3306,db2:3307", Database: "test", ConnectTimeout: 5 * time.Second,
+                ReadTimeout: 1500 * time.Millisecond, WriteTimeout: time.Minute, Role: ROLE_PREFER_REPLICA,
+                RandomHosts: true, HostBlacklistTime: 30 * time.Second},
+            "tcp(db1:3306,db2:3307)/test?hostBlacklistTime=30s&randomHosts=true&readTimeout=1.5s&role=prefer-replica&timeout=5s&writeTimeout=1m0s",
+        },
+        {
+            Config{Net: "tcp", Uri: "db:3306", Database: "test", Charset: "latin1", Collation: "latin1_swedish_ci",
+                ConnectionAttributes: map[string]string{"program": "app", "pid": "1"},
+                Params: map[string]string{"sql_mode": "'ANSI,TRADITIONAL'", "time_zone": "'+00:00'"}},
+            "tcp(db:3306)/test?charset=latin1&collation=latin1_swedish_ci&connectionAttributes=pid%3A1%2Cprogram%3Aapp&" +
+                "sql_mode=%27ANSI%2CTRADITIONAL%27&time_zone=%27%2B00%3A00%27",
+        },
+    }
+    for _, test := range tests {
+        dsn := FormatDSN(test.config)
+        if dsn != test.dsn {
+            t.Errorf("expected DSN %s, got %s", test.dsn, dsn)
+        }
+        config, err := ParseDSN(dsn)
+        if err != nil {
+            t.Errorf("%s: %v", dsn, err)
+        } else if !reflect.DeepEqual(config, test.config) {
+            t.Errorf("%s: expected %+v, got %+v", dsn, test.config, config)
+        }
+    }
+
+    // deprecated Timeout is formatted as timeout
+    dsn := FormatDSN(Config{Uri: "db:3306", Timeout: time.Second})
+    if dsn != "tcp(db:3306)/?timeout=1s" {
+        t.Errorf("unexpected DSN %s", dsn)
+    }
+}
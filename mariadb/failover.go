@@ -0,0 +1,275 @@
+package mariadb
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "math/rand"
+    "net"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Roles of server selected from Config.Uri with several hosts
+const ROLE_ANY = "any"
+const ROLE_PRIMARY = "primary"
+const ROLE_REPLICA = "replica"
+const ROLE_PREFER_REPLICA = "prefer-replica"
+
+// Period host is skipped for after failed connection,
+// used when Config.HostBlacklistTime is zero
+const DefaultHostBlacklistTime = 30 * time.Second
+
+// See https://mariadb.com/kb/en/mariadb-error-codes/
+const ER_OPTION_PREVENTS_STATEMENT = 1290
+const ER_READ_ONLY_MODE = 1836
+
+// Returned when no host of Config.Uri matches role
+type HostsError struct {
+    Role string
+    // Reasons hosts were rejected for, in order they were tried
+    Failures []string
+    // Last connection error
+    Err error
+}
+
+func (e *HostsError) Error() string {
+    return fmt.Sprintf("no %s host available: %s", e.Role, strings.Join(e.Failures, "; "))
+}
+
+func (e *HostsError) Unwrap() error {
+    return e.Err
+}
+
+// Blacklists of host sets, connections with the same hosts and role
+// share blacklist, so hosts of other Uri are not affected
+var blacklists = struct {
+    mu sync.Mutex
+    byHosts map[string]*hostBlacklist
+}{byHosts: map[string]*hostBlacklist{}}
+
+// Hosts which failed recently
+type hostBlacklist struct {
+    mu sync.Mutex
+    until map[string]time.Time
+}
+
+func (b *hostBlacklist) add(addr string, d time.Duration) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.until[addr] = time.Now().Add(d)
+}
+
+func (b *hostBlacklist) remove(addr string) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    delete(b.until, addr)
+}
+
+func (b *hostBlacklist) contains(addr string) bool {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    until, ok := b.until[addr]
+    if ok && time.Now().After(until) {
+        delete(b.until, addr)
+        return false
+    }
+    return ok
+}
+
+// Blacklist of hosts of Config.Uri tried for role
+func (config *Config) blacklist(role string) *hostBlacklist {
+    hosts := config.hosts()
+    sort.Strings(hosts)
+    key := role + "/" + strings.Join(hosts, ",")
+    blacklists.mu.Lock()
+    defer blacklists.mu.Unlock()
+    b, ok := blacklists.byHosts[key]
+    if !ok {
+        b = &hostBlacklist{until: map[string]time.Time{}}
+        blacklists.byHosts[key] = b
+    }
+    return b
+}
+
+// Host is blacklisted when it can't be reached. Errors sent by server,
+// e.g. denied access, don't mean that other hosts are better.
+func isNetworkError(err error) bool {
+    var netErr net.Error
+    return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Addresses of Config.Uri separated with comma
+func (config *Config) hosts() []string {
+    hosts := []string{}
+    for _, host := range strings.Split(config.Uri, ",") {
+        host = strings.TrimSpace(host)
+        if host != "" {
+            hosts = append(hosts, host)
+        }
+    }
+    return hosts
+}
+
+// Hosts in order they are tried, blacklisted hosts go last
+func (config *Config) hostOrder(blacklist *hostBlacklist) []string {
+    hosts := config.hosts()
+    if config.RandomHosts {
+        rand.Shuffle(len(hosts), func(i, j int) {
+            hosts[i], hosts[j] = hosts[j], hosts[i]
+        })
+    }
+    available := []string{}
+    blocked := []string{}
+    for _, host := range hosts {
+        if blacklist.contains(host) {
+            blocked = append(blocked, host)
+        } else {
+            available = append(available, host)
+        }
+    }
+    return append(available, blocked...)
+}
+
+// Connect to first host matching Config.Role. Unreachable hosts and hosts
+// of other role are blacklisted for Config.HostBlacklistTime. Called before
+// goroutine serving the queue is started or by it when there are no
+// responses to read.
+func (c *Connection) open(ctx context.Context) error {
+    role := c.config.Role
+    if role == "" {
+        role = ROLE_ANY
+    }
+    switch role {
+    case ROLE_ANY, ROLE_PRIMARY, ROLE_REPLICA, ROLE_PREFER_REPLICA:
+    default:
+        return fmt.Errorf("unknown role '%s'", role)
+    }
+    blacklistTime := c.config.HostBlacklistTime
+    if blacklistTime == 0 {
+        blacklistTime = DefaultHostBlacklistTime
+    }
+
+    blacklist := c.config.blacklist(role)
+    hosts := c.config.hostOrder(blacklist)
+    if len(hosts) == 0 {
+        return fmt.Errorf("no hosts in Uri")
+    }
+    var lastErr error
+    failures := []string{}
+    fallback := ""
+    for _, host := range hosts {
+        primary, err := c.openHost(ctx, host, role != ROLE_ANY)
+        if err != nil {
+            if ctx.Err() != nil {
+                return err
+            }
+            if isNetworkError(err) {
+                blacklist.add(host, blacklistTime)
+            }
+            if len(hosts) == 1 && role == ROLE_ANY {
+                return err
+            }
+            lastErr = err
+            failures = append(failures, host + ": " + err.Error())
+            continue
+        }
+        switch {
+        case role == ROLE_ANY,
+            role == ROLE_PRIMARY && primary,
+            (role == ROLE_REPLICA || role == ROLE_PREFER_REPLICA) && !primary:
+            blacklist.remove(host)
+            return nil
+        }
+        c.socket.Close()
+        // role changes only with failover, host is tried last meanwhile
+        blacklist.add(host, blacklistTime)
+        if role == ROLE_PREFER_REPLICA && fallback == "" {
+            fallback = host
+        }
+        failures = append(failures, host + ": server isn't " + role)
+    }
+
+    if fallback != "" {
+        _, err := c.openHost(ctx, fallback, false)
+        if err == nil {
+            return nil
+        }
+        lastErr = err
+        failures = append(failures, fallback + ": " + err.Error())
+    }
+    return &HostsError{Role: role, Failures: failures, Err: lastErr}
+}
+
+// Dial host and do handshake. Returns true when server is writable
+// primary, role is detected only when detect is true.
+func (c *Connection) openHost(ctx context.Context, host string, detect bool) (bool, error) {
+    timeout := c.config.ConnectTimeout
+    if timeout == 0 {
+        timeout = c.config.Timeout
+    }
+    socket, err := c.config.dial(ctx, host, timeout)
+    if err != nil {
+        return false, err
+    }
+    if timeout > 0 {
+        socket.SetDeadline(time.Now().Add(timeout))
+    }
+    c.socket = socket
+    c.host.Store(host)
+    c.ready = false
+    err = interruptible(ctx, func() error {
+        err := c.init()
+        if err == nil && detect {
+            detect, err = c.isPrimary()
+        }
+        return err
+    }, func() {
+        // unblock reads and writes of handshake
+        socket.SetDeadline(time.Now())
+    })
+    if err != nil {
+        socket.Close()
+        return false, err
+    }
+    socket.SetDeadline(time.Time{})
+    return detect, nil
+}
+
+// Server is primary when it isn't read only.
+// See https://mariadb.com/kb/en/server-system-variables/#read_only
+func (c *Connection) isPrimary() (bool, error) {
+    q := createQueuePacket(createQueryPacket("SELECT @@global.read_only, @@global.innodb_read_only"))
+    err := c.send(q.packet)
+    if err != nil {
+        return false, err
+    }
+    go c.recvResponse(&q)
+    rows, err := c.readRows(context.Background(), q.c)
+    if err != nil {
+        drainResponse(q.c)
+        return false, err
+    }
+    defer rows.Close()
+    if !rows.Next() {
+        if rows.Err() != nil {
+            return false, rows.Err()
+        }
+        return false, fmt.Errorf("unexpected result of read_only check")
+    }
+    var readOnly, innodbReadOnly int64
+    err = rows.Scan(&readOnly, &innodbReadOnly)
+    if err != nil {
+        return false, fmt.Errorf("unexpected result of read_only check: %w", err)
+    }
+    return readOnly == 0 && innodbReadOnly == 0, nil
+}
+
+// Address of server connection is established with
+func (c *Connection) Host() string {
+    host, _ := c.host.Load().(string)
+    return host
+}
@@ -0,0 +1,174 @@
+package mariadb
+
+import (
+    "context"
+    "errors"
+    "net"
+    "strings"
+    "sync/atomic"
+    "testing"
+)
+
+// Server reporting read_only and innodb_read_only variables,
+// updates fail while server is read only
+type roleServer struct {
+    *testServer
+    readOnly atomic.Value
+}
+
+func newRoleServer(t *testing.T, readOnly, innodbReadOnly string) *roleServer {
+    s := &roleServer{}
+    s.setReadOnly(readOnly, innodbReadOnly)
+    s.testServer = newTestServer(t, func(conn net.Conn, cmd []byte) [][]byte {
+        values := s.readOnly.Load().([]interface{})
+        query := commandQuery(cmd)
+        switch {
+        case query == "SELECT @@global.read_only, @@global.innodb_read_only":
+            columns := []string{"@@global.read_only", "@@global.innodb_read_only"}
+            return testResultSet(SERVER_STATUS_AUTOCOMMIT, columns, values)
+        case strings.HasPrefix(query, "UPDATE") && values[0] != "0":
+            return [][]byte{testErr(ER_OPTION_PREVENTS_STATEMENT, "The MariaDB server is running with the --read-only option")}
+        }
+        return [][]byte{testOK(1, 0, SERVER_STATUS_AUTOCOMMIT)}
+    })
+    return s
+}
+
+func (s *roleServer) setReadOnly(readOnly, innodbReadOnly string) {
+    s.readOnly.Store([]interface{}{readOnly, innodbReadOnly})
+}
+
+func (s *roleServer) addr() string {
+    return s.ln.Addr().String()
+}
+
+// Address nothing listens on
+func deadAddr(t *testing.T) string {
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    ln.Close()
+    return ln.Addr().String()
+}
+
+func hostsConfig(role string, hosts ...string) Config {
+    return Config{Uri: strings.Join(hosts, ","), Role: role, Username: "test", Password: "test"}
+}
+
+func TestFailoverSelectsRole(t *testing.T) {
+    replica := newRoleServer(t, "1", "0")
+    primary := newRoleServer(t, "0", "0")
+    innodbReplica := newRoleServer(t, "0", "1")
+    hosts := []string{innodbReplica.addr(), replica.addr(), primary.addr()}
+
+    for role, expected := range map[string]string{
+        ROLE_PRIMARY: primary.addr(),
+        ROLE_REPLICA: innodbReplica.addr(),
+        ROLE_ANY: innodbReplica.addr(),
+    } {
+        conn, err := Connect(hostsConfig(role, hosts...), context.Background())
+        if err != nil {
+            t.Fatalf("%s: %v", role, err)
+        }
+        if conn.Host() != expected {
+            t.Errorf("%s: connected to %s, expected %s", role, conn.Host(), expected)
+        }
+        conn.Close()
+    }
+
+    // hosts of other role are tried last
+    config := hostsConfig(ROLE_PRIMARY, hosts...)
+    blacklist := config.blacklist(ROLE_PRIMARY)
+    if !blacklist.contains(replica.addr()) || !blacklist.contains(innodbReplica.addr()) || blacklist.contains(primary.addr()) {
+        t.Errorf("unexpected blacklist %v", blacklist.until)
+    }
+    if order := config.hostOrder(blacklist); order[0] != primary.addr() {
+        t.Errorf("primary isn't tried first: %v", order)
+    }
+}
+
+func TestFailoverNoHostOfRole(t *testing.T) {
+    replica := newRoleServer(t, "1", "1")
+    _, err := Connect(hostsConfig(ROLE_PRIMARY, replica.addr()), context.Background())
+    var hostsErr *HostsError
+    if !errors.As(err, &hostsErr) || hostsErr.Role != ROLE_PRIMARY {
+        t.Fatalf("expected HostsError, got %v", err)
+    }
+}
+
+func TestFailoverBlacklistIsScopedToHosts(t *testing.T) {
+    dead := deadAddr(t)
+    s := newRoleServer(t, "0", "0")
+    config := hostsConfig(ROLE_ANY, dead, s.addr())
+    conn, err := Connect(config, context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    conn.Close()
+    if !config.blacklist(ROLE_ANY).contains(dead) {
+        t.Error("unreachable host isn't blacklisted")
+    }
+
+    for _, other := range []Config{hostsConfig(ROLE_ANY, dead, deadAddr(t)), hostsConfig(ROLE_PRIMARY, dead, s.addr())} {
+        blacklist := other.blacklist(other.Role)
+        if blacklist.contains(dead) {
+            t.Errorf("host is blacklisted for %s %s", other.Role, other.Uri)
+        }
+        if order := other.hostOrder(blacklist); order[0] != dead {
+            t.Errorf("unexpected order %v for %s %s", order, other.Role, other.Uri)
+        }
+    }
+}
+
+func TestFailoverServerErrorIsNotBlacklisted(t *testing.T) {
+    denied := newRoleServer(t, "0", "0")
+    denied.setAuthResponse(testErr(1045, "Access denied for user 'test'"))
+    s := newRoleServer(t, "0", "0")
+    config := hostsConfig(ROLE_ANY, denied.addr(), s.addr())
+    conn, err := Connect(config, context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    conn.Close()
+    if conn.Host() != s.addr() {
+        t.Errorf("connected to %s", conn.Host())
+    }
+    if config.blacklist(ROLE_ANY).contains(denied.addr()) {
+        t.Error("host is blacklisted after access denied error")
+    }
+}
+
+func TestFailoverFollowsNewPrimary(t *testing.T) {
+    for _, autoReconnect := range []bool{true, false} {
+        first := newRoleServer(t, "0", "0")
+        second := newRoleServer(t, "1", "0")
+        config := hostsConfig(ROLE_PRIMARY, first.addr(), second.addr())
+        config.AutoReconnect = autoReconnect
+        conn, err := Connect(config, context.Background())
+        if err != nil {
+            t.Fatal(err)
+        }
+        defer conn.Close()
+        if conn.Host() != first.addr() {
+            t.Fatalf("connected to %s", conn.Host())
+        }
+
+        first.setReadOnly("1", "0")
+        second.setReadOnly("0", "0")
+        _, err = conn.Exec("UPDATE t SET a = 1")
+        var mysqlErr *MysqlError
+        if !errors.As(err, &mysqlErr) || mysqlErr.Code != ER_OPTION_PREVENTS_STATEMENT {
+            t.Fatalf("expected read only error, got %v", err)
+        }
+
+        _, err = conn.Exec("UPDATE t SET a = 1")
+        if autoReconnect {
+            if err != nil || conn.Host() != second.addr() {
+                t.Errorf("connection doesn't follow failover: %v, host %s", err, conn.Host())
+            }
+        } else if err == nil || conn.Host() != first.addr() {
+            t.Errorf("connection without AutoReconnect is reopened: %v, host %s", err, conn.Host())
+        }
+    }
+}
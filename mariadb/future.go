@@ -0,0 +1,111 @@
+package mariadb
+
+import (
+    "context"
+    "sync"
+)
+
+// Result of query running in background.
+//
+//	users := client.QueryAsync(ctx, "SELECT * FROM users WHERE id = ?", 1)
+//	orders := client.QueryAsync(ctx, "SELECT * FROM orders WHERE user_id = ?", 1)
+//	if err := users.Wait(ctx); err != nil {
+//	    log.Fatal(err)
+//	}
+//	rows, err := orders.Rows()
+type Future struct {
+    done chan struct{}
+    rows QueryResultRows
+    result Result
+    err error
+}
+
+// Serialize sending of futures, so they run in order they were created
+type asyncQueue struct {
+    mu sync.Mutex
+    tail chan struct{}
+}
+
+// Run query in background. Queries of futures run in order they were created.
+// Query is killed when ctx is done like in QueryContext, query which isn't
+// sent yet is skipped. Queries with cancellable context are sent alone,
+// so KILL QUERY can't hit other command, others are pipelined.
+func (c *Connection) QueryAsync(ctx context.Context, query string, args ...interface{}) *Future {
+    f := &Future{done: make(chan struct{})}
+    if c.heldByCaller() {
+        f.err = ErrTxActive
+        close(f.done)
+        return f
+    }
+    if len(args) > 0 {
+        var err error
+        query, err = c.interpolate(query, args)
+        if err != nil {
+            f.err = err
+            close(f.done)
+            return f
+        }
+    }
+
+    q := createQueuePacket(createQueryPacket(query))
+    q.ctx = ctx
+
+    c.async.mu.Lock()
+    previous := c.async.tail
+    sent := make(chan struct{})
+    c.async.tail = sent
+    c.async.mu.Unlock()
+
+    go func() {
+        defer close(f.done)
+        if previous != nil {
+            <-previous
+        }
+        select {
+        case c.packetQueue <- q:
+        case <-ctx.Done():
+            // queue may be held by other command, query is skipped
+            q.c <- createQueuePacketError(ctx.Err())
+            close(q.c)
+        case <-c.ctx.Done():
+            q.c <- createQueuePacketError(c.closedError())
+            close(q.c)
+        }
+        close(sent)
+        f.rows, f.result, f.err = c.readResponse(ctx, q.c)
+    }()
+    return f
+}
+
+// Run statement which doesn't return rows in background, see QueryAsync
+func (c *Connection) ExecAsync(ctx context.Context, query string, args ...interface{}) *Future {
+    return c.QueryAsync(ctx, query, args...)
+}
+
+// Closed when query is finished
+func (f *Future) Done() <-chan struct{} {
+    return f.done
+}
+
+// Wait until query is finished and return its error.
+// Returns context error when context is done first, query keeps running.
+func (f *Future) Wait(ctx context.Context) error {
+    select {
+    case <-f.done:
+        return f.err
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// Wait until statement is finished and return its result
+func (f *Future) Result() (Result, error) {
+    <-f.done
+    return f.result, f.err
+}
+
+// Wait until query is finished and return its rows
+func (f *Future) Rows() (QueryResultRows, error) {
+    <-f.done
+    return f.rows, f.err
+}
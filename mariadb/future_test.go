@@ -0,0 +1,125 @@
+package mariadb
+
+import (
+    "context"
+    "fmt"
+    "testing"
+    "time"
+)
+
+// Wait for future, fail test when it hangs
+func waitFuture(t *testing.T, f *Future) error {
+    t.Helper()
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    if err := f.Wait(ctx); err == context.DeadlineExceeded {
+        t.Fatal("future isn't finished")
+    }
+    return f.err
+}
+
+func TestFuturesRunInOrder(t *testing.T) {
+    s := newPipelineServer(t)
+    conn := s.connect(t, s.config())
+
+    blocked := conn.QueryAsync(context.Background(), "BLOCK")
+    futures := []*Future{blocked}
+    for _, query := range []string{"SELECT 1", "SELECT 2", "SELECT 3"} {
+        futures = append(futures, conn.QueryAsync(context.Background(), query))
+    }
+    // pipelined behind blocked query
+    waitFor(t, func() bool {
+        return len(conn.pending) == 3
+    })
+    select {
+    case <-blocked.Done():
+        t.Fatal("blocked query is finished")
+    default:
+    }
+    s.unblock()
+
+    for i, query := range []string{"BLOCK", "SELECT 1", "SELECT 2", "SELECT 3"} {
+        rows, err := futures[i].Rows()
+        checkEcho(t, query, rows, err)
+    }
+    if queries := s.queries(); len(queries) != 4 || queries[1] != "SELECT 1" || queries[3] != "SELECT 3" {
+        t.Errorf("unexpected order of queries %q", queries)
+    }
+}
+
+func TestFutureWaitContext(t *testing.T) {
+    s := newPipelineServer(t)
+    conn := s.connect(t, s.config())
+
+    f := conn.QueryAsync(context.Background(), "BLOCK")
+    ctx, cancel := context.WithTimeout(context.Background(), 20 * time.Millisecond)
+    defer cancel()
+    if err := f.Wait(ctx); err != context.DeadlineExceeded {
+        t.Errorf("expected deadline error, got %v", err)
+    }
+    s.unblock()
+    if err := waitFuture(t, f); err != nil {
+        t.Errorf("query isn't finished after Wait gave up: %v", err)
+    }
+}
+
+func TestFutureCancelBeforeSend(t *testing.T) {
+    s := newPipelineServer(t)
+    conn := s.connect(t, s.config())
+
+    // command which can be killed holds queue until its response is read
+    blockCtx, cancelBlock := context.WithCancel(context.Background())
+    defer cancelBlock()
+    blocked := make(chan error)
+    go func() {
+        _, err := conn.QueryContext(blockCtx, "BLOCK")
+        blocked <- err
+    }()
+    s.waitQuery(t, "BLOCK")
+
+    ctx, cancel := context.WithCancel(context.Background())
+    f := conn.QueryAsync(ctx, "SELECT 2")
+    cancel()
+    if err := waitFuture(t, f); err != context.Canceled {
+        t.Errorf("expected cancelled future, got %v", err)
+    }
+
+    s.unblock()
+    if err := <-blocked; err != nil {
+        t.Fatal(err)
+    }
+    rows, err := conn.Query("SELECT 3")
+    checkEcho(t, "SELECT 3", rows, err)
+    for _, query := range s.queries() {
+        if query == "SELECT 2" {
+            t.Error("cancelled future is sent")
+        }
+    }
+}
+
+func TestFutureCancelKillsQuery(t *testing.T) {
+    s := newPipelineServer(t)
+    conn := s.connect(t, s.config())
+
+    ctx, cancel := context.WithCancel(context.Background())
+    killed := conn.QueryAsync(ctx, "BLOCK")
+    next := conn.QueryAsync(context.Background(), "SELECT 2")
+    s.waitQuery(t, "BLOCK")
+    time.Sleep(20 * time.Millisecond)
+    // query which can be killed is sent alone
+    if queries := s.queries(); len(queries) != 1 {
+        t.Errorf("command is sent before response of query which can be killed: %q", queries)
+    }
+    cancel()
+    if err := waitFuture(t, killed); err != context.Canceled {
+        t.Errorf("expected cancelled future, got %v", err)
+    }
+    s.waitQuery(t, fmt.Sprintf("KILL QUERY %d", conn.info.connectionId))
+
+    // kill doesn't hit next query
+    if err := waitFuture(t, next); err != nil {
+        t.Fatal(err)
+    }
+    rows, err := next.Rows()
+    checkEcho(t, "SELECT 2", rows, err)
+}
@@ -2,6 +2,7 @@ package mariadb
 
 import (
     "math"
+    "sort"
     "github.com/vasflam/lab-mysql-connector/mariadb/capabilities"
 )
 
@@ -88,6 +89,14 @@ func createHandshakeResponsePacket(
          clientCapabilities |= capabilities.CONNECT_WITH_DB
      }
 
+     if config.TLS != nil {
+         clientCapabilities |= capabilities.SSL
+     }
+
+     if len(config.ConnectionAttributes) > 0 && (hsreq.capabilities & capabilities.CONNECT_ATTRS != 0) {
+         clientCapabilities |= capabilities.CONNECT_ATTRS
+     }
+
      var authToken []byte
      var authPlugin string
      switch pluginName := hsreq.pluginName; pluginName {
@@ -134,13 +143,49 @@ func createHandshakeResponsePacket(
         packet.writeUInt8(0)
     }
 
-    if hsreq.capabilities & capabilities.CONNECT_ATTRS != 0 {
-        packet.writeUInt8(0)
+    if clientCapabilities & capabilities.CONNECT_ATTRS != 0 {
+        writeConnectionAttributes(packet, config.ConnectionAttributes)
     }
 
     packet.updateHeader()
-    packet.setSequence(1)
+    if clientCapabilities & capabilities.SSL != 0 {
+        // SSL request was sent before
+        packet.setSequence(2)
+    } else {
+        packet.setSequence(1)
+    }
     info.clientCapabilities = clientCapabilities
     return packet
 }
 
+
+// Key-value pairs shown in performance_schema.session_connect_attrs
+func writeConnectionAttributes(packet *Packet, attrs map[string]string) {
+    keys := make([]string, 0, len(attrs))
+    for key := range attrs {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    data := &Packet{}
+    for _, key := range keys {
+        data.writeLengthEncoded(uint64(len(key)))
+        data.writeBytes([]byte(key))
+        data.writeLengthEncoded(uint64(len(attrs[key])))
+        data.writeBytes([]byte(attrs[key]))
+    }
+    packet.writeLengthEncoded(uint64(len(data.bytes())))
+    packet.writeBytes(data.bytes())
+}
+
+// SSL request is the beginning of handshake response: capabilities,
+// max packet size, collation and reserved bytes.
+// See https://mariadb.com/kb/en/connection/#sslrequest-packet
+func createSSLRequestPacket(response *Packet) *Packet {
+    packet := &Packet{}
+    packet.writeEmptyHeader()
+    packet.writeBytes(response.bytes()[4:36])
+    packet.updateHeader()
+    packet.setSequence(1)
+    return packet
+}
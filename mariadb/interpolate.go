@@ -0,0 +1,237 @@
+package mariadb
+
+import (
+    "database/sql/driver"
+    "encoding/hex"
+    "fmt"
+    "math"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Multi-byte charsets which can contain backslash byte in second
+// byte of character. Escaping on client side isn't safe for them.
+var unsafeCharsets = map[string]bool{
+    "big5": true,
+    "sjis": true,
+    "gbk": true,
+    "cp932": true,
+    "gb18030": true,
+}
+
+// Charsets in which query is sent as is
+var utf8Charsets = map[string]bool{
+    "": true,
+    "utf8": true,
+    "utf8mb4": true,
+    "binary": true,
+}
+
+// Replace ? placeholders with escaped arguments. Placeholders inside string
+// literals, quoted identifiers and comments are left as is. Query is encoded
+// to single byte connection charset, other charsets except UTF-8 are refused.
+func (c *Connection) interpolate(query string, args []interface{}) (string, error) {
+    charset := collationCharset(uint16(c.collation.Load()))
+    if unsafeCharsets[charset] {
+        return "", fmt.Errorf("interpolation is unsafe with connection charset %s", charset)
+    }
+    table, singleByte := charsetTables[charset]
+    if !singleByte && !utf8Charsets[charset] {
+        return "", fmt.Errorf("interpolation doesn't support connection charset %s", charset)
+    }
+    noBackslashEscapes := c.status.Load() & SERVER_STATUS_NO_BACKSLASH_ESCAPES != 0
+
+    var buf strings.Builder
+    n := 0
+    for i := 0; i < len(query); {
+        if j := skipLiteral(query, i, noBackslashEscapes); j > i {
+            buf.WriteString(query[i:j])
+            i = j
+            continue
+        }
+        if query[i] != '?' {
+            buf.WriteByte(query[i])
+            i++
+            continue
+        }
+        if n >= len(args) {
+            return "", fmt.Errorf("query has more placeholders than %d arguments", len(args))
+        }
+        err := writeLiteral(&buf, args[n], noBackslashEscapes)
+        if err != nil {
+            return "", fmt.Errorf("argument %d: %w", n, err)
+        }
+        n++
+        i++
+    }
+    if n != len(args) {
+        return "", fmt.Errorf("query has %d placeholders, got %d arguments", n, len(args))
+    }
+    if singleByte {
+        encoded, err := encodeSingleByte(table, buf.String())
+        if err != nil {
+            return "", fmt.Errorf("query can't be sent in charset %s: %w", charset, err)
+        }
+        return encoded, nil
+    }
+    return buf.String(), nil
+}
+
+// If string literal, quoted identifier or comment starts at position i
+// return position after its end, otherwise return i.
+func skipLiteral(query string, i int, noBackslashEscapes bool) int {
+    switch ch := query[i]; {
+    case ch == '\'' || ch == '"' || ch == '`':
+        j := i + 1
+        for j < len(query) {
+            switch query[j] {
+            case '\\':
+                if ch != '`' && !noBackslashEscapes {
+                    j++
+                }
+            case ch:
+                // quote is escaped by doubling it
+                if j + 1 < len(query) && query[j + 1] == ch {
+                    j++
+                } else {
+                    return j + 1
+                }
+            }
+            j++
+        }
+        return len(query)
+    case ch == '#' || (ch == '-' && strings.HasPrefix(query[i:], "--") &&
+        (i + 2 == len(query) || query[i + 2] <= ' ')):
+        j := strings.IndexByte(query[i:], '\n')
+        if j < 0 {
+            return len(query)
+        }
+        return i + j + 1
+    case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+        j := strings.Index(query[i + 2:], "*/")
+        if j < 0 {
+            return len(query)
+        }
+        return i + 2 + j + 2
+    }
+    return i
+}
+
+// Write value as SQL literal
+func writeLiteral(buf *strings.Builder, arg interface{}, noBackslashEscapes bool) error {
+    if valuer, ok := arg.(driver.Valuer); ok {
+        value, err := valuer.Value()
+        if err != nil {
+            return err
+        }
+        arg = value
+    }
+
+    switch v := arg.(type) {
+    case nil:
+        buf.WriteString("NULL")
+        return nil
+    case bool:
+        if v {
+            buf.WriteString("1")
+        } else {
+            buf.WriteString("0")
+        }
+        return nil
+    case string:
+        writeQuoted(buf, v, noBackslashEscapes)
+        return nil
+    case []byte:
+        if v == nil {
+            buf.WriteString("NULL")
+            return nil
+        }
+        buf.WriteString("X'")
+        buf.WriteString(hex.EncodeToString(v))
+        buf.WriteString("'")
+        return nil
+    case time.Time:
+        buf.WriteString("'")
+        if v.IsZero() {
+            buf.WriteString("0000-00-00")
+        } else {
+            // values are read back in UTC, see parseDateTime
+            buf.WriteString(v.UTC().Format("2006-01-02 15:04:05.999999"))
+        }
+        buf.WriteString("'")
+        return nil
+    case time.Duration:
+        buf.WriteString("'")
+        buf.WriteString(formatTime(v))
+        buf.WriteString("'")
+        return nil
+    }
+
+    rv := reflect.ValueOf(arg)
+    switch rv.Kind() {
+    case reflect.Ptr:
+        if rv.IsNil() {
+            buf.WriteString("NULL")
+            return nil
+        }
+        return writeLiteral(buf, rv.Elem().Interface(), noBackslashEscapes)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        buf.WriteString(strconv.FormatInt(rv.Int(), 10))
+        return nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        buf.WriteString(strconv.FormatUint(rv.Uint(), 10))
+        return nil
+    case reflect.Float32, reflect.Float64:
+        f := rv.Float()
+        if math.IsNaN(f) || math.IsInf(f, 0) {
+            return fmt.Errorf("%v can't be stored in database", f)
+        }
+        buf.WriteString(strconv.FormatFloat(f, 'g', -1, rv.Type().Bits()))
+        return nil
+    case reflect.Bool:
+        return writeLiteral(buf, rv.Bool(), noBackslashEscapes)
+    case reflect.String:
+        writeQuoted(buf, rv.String(), noBackslashEscapes)
+        return nil
+    case reflect.Slice:
+        if rv.Type().Elem().Kind() == reflect.Uint8 {
+            return writeLiteral(buf, rv.Bytes(), noBackslashEscapes)
+        }
+    }
+    return fmt.Errorf("unsupported type %T", arg)
+}
+
+// Write string in single quotes. When NO_BACKSLASH_ESCAPES sql mode is
+// enabled only quote is escaped by doubling it.
+// See https://mariadb.com/kb/en/string-literals/
+func writeQuoted(buf *strings.Builder, s string, noBackslashEscapes bool) {
+    buf.WriteByte('\'')
+    for i := 0; i < len(s); i++ {
+        ch := s[i]
+        if noBackslashEscapes {
+            if ch == '\'' {
+                buf.WriteByte('\'')
+            }
+            buf.WriteByte(ch)
+            continue
+        }
+        switch ch {
+        case 0:
+            buf.WriteString("\\0")
+        case '\n':
+            buf.WriteString("\\n")
+        case '\r':
+            buf.WriteString("\\r")
+        case '\x1a':
+            buf.WriteString("\\Z")
+        case '\'', '"', '\\':
+            buf.WriteByte('\\')
+            buf.WriteByte(ch)
+        default:
+            buf.WriteByte(ch)
+        }
+    }
+    buf.WriteByte('\'')
+}
@@ -0,0 +1,171 @@
+package mariadb
+
+import (
+    "math"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestWriteQuoted(t *testing.T) {
+    tests := []struct {
+        value string
+        expected string
+        noBackslashEscapes string
+    }{
+        {"abc", `'abc'`, `'abc'`},
+        {"it's", `'it\'s'`, `'it''s'`},
+        {`say "hi"`, `'say \"hi\"'`, `'say "hi"'`},
+        {`C:\dir`, `'C:\\dir'`, `'C:\dir'`},
+        {"a\x00b\nc\rd\x1ae", `'a\0b\nc\rd\Ze'`, "'a\x00b\nc\rd\x1ae'"},
+        {`\'`, `'\\\''`, `'\'''`},
+        {"юникод", `'юникод'`, `'юникод'`},
+    }
+    for _, test := range tests {
+        var buf strings.Builder
+        writeQuoted(&buf, test.value, false)
+        if buf.String() != test.expected {
+            t.Errorf("%q: expected %s, got %s", test.value, test.expected, buf.String())
+        }
+        buf.Reset()
+        writeQuoted(&buf, test.value, true)
+        if buf.String() != test.noBackslashEscapes {
+            t.Errorf("%q with NO_BACKSLASH_ESCAPES: expected %s, got %s", test.value, test.noBackslashEscapes, buf.String())
+        }
+    }
+}
+
+func TestSkipLiteral(t *testing.T) {
+    tests := []struct {
+        query string
+        noBackslashEscapes bool
+        end int
+    }{
+        {"'abc' x", false, 5},
+        {`"abc" x`, false, 5},
+        {"`a?c` x", false, 5},
+        {"'it''s' x", false, 7},
+        {`'a\'b' x`, false, 6},
+        // backslash is literal, quote ends string
+        {`'a\'b' x`, true, 4},
+        // backslash doesn't escape in quoted identifier
+        {"`a\\` x", false, 4},
+        {"'unterminated", false, 13},
+        {"# comment ?\nx", false, 12},
+        {"-- comment ?\nx", false, 13},
+        {"--", false, 2},
+        // -- without following space isn't comment
+        {"--1", false, 0},
+        {"/* ? */ x", false, 7},
+        {"/* unterminated", false, 15},
+        {"x = 1", false, 0},
+        {"- 1", false, 0},
+        {"/ 2", false, 0},
+    }
+    for _, test := range tests {
+        if end := skipLiteral(test.query, 0, test.noBackslashEscapes); end != test.end {
+            t.Errorf("%q: expected end %d, got %d", test.query, test.end, end)
+        }
+    }
+}
+
+func TestInterpolate(t *testing.T) {
+    moscow := time.FixedZone("MSK", 3 * 60 * 60)
+    name := "bob"
+    var nilName *string
+    tests := []struct {
+        query string
+        args []interface{}
+        expected string
+        err string
+    }{
+        {"SELECT ?, ?", []interface{}{1, "a"}, "SELECT 1, 'a'", ""},
+        {"SELECT '?', \"?\", `?`, ?", []interface{}{2}, "SELECT '?', \"?\", `?`, 2", ""},
+        {"SELECT 'it''s ?', ?", []interface{}{3}, "SELECT 'it''s ?', 3", ""},
+        {"SELECT 'a\\'?', ?", []interface{}{4}, "SELECT 'a\\'?', 4", ""},
+        {"SELECT ? # ?\n, ?", []interface{}{5, 6}, "SELECT 5 # ?\n, 6", ""},
+        {"SELECT ? -- ?\n, ?", []interface{}{5, 6}, "SELECT 5 -- ?\n, 6", ""},
+        {"SELECT ? /* ? */", []interface{}{7}, "SELECT 7 /* ? */", ""},
+        {"SELECT ?, ?, ?", []interface{}{nil, true, false}, "SELECT NULL, 1, 0", ""},
+        {"SELECT ?, ?", []interface{}{&name, nilName}, "SELECT 'bob', NULL", ""},
+        {"SELECT ?, ?", []interface{}{[]byte("a'\x00"), []byte(nil)}, "SELECT X'612700', NULL", ""},
+        {"SELECT ?", []interface{}{[]byte{}}, "SELECT X''", ""},
+        {"SELECT ?, ?", []interface{}{uint8(255), int64(-9)}, "SELECT 255, -9", ""},
+        {"SELECT ?, ?", []interface{}{1.5, float32(0.25)}, "SELECT 1.5, 0.25", ""},
+        {"SELECT ?", []interface{}{time.Date(2023, 5, 6, 1, 2, 3, 456000000, moscow)}, "SELECT '2023-05-05 22:02:03.456'", ""},
+        {"SELECT ?", []interface{}{time.Time{}}, "SELECT '0000-00-00'", ""},
+        {"SELECT ?", []interface{}{90 * time.Minute}, "SELECT '01:30:00'", ""},
+        {"SELECT ?", []interface{}{math.NaN()}, "", "can't be stored"},
+        {"SELECT ?", []interface{}{math.Inf(1)}, "", "can't be stored"},
+        {"SELECT ?", []interface{}{math.Inf(-1)}, "", "can't be stored"},
+        {"SELECT ?", []interface{}{struct{}{}}, "", "unsupported type"},
+        {"SELECT ?, ?", []interface{}{1}, "", "more placeholders"},
+        {"SELECT ?", []interface{}{1, 2}, "", "1 placeholders, got 2"},
+        {"SELECT '?'", []interface{}{1}, "", "0 placeholders, got 1"},
+    }
+    c := &Connection{}
+    c.collation.Store(45) // utf8mb4_general_ci
+    for _, test := range tests {
+        query, err := c.interpolate(test.query, test.args)
+        if test.err != "" {
+            if err == nil || !strings.Contains(err.Error(), test.err) {
+                t.Errorf("%q: expected error %q, got %v", test.query, test.err, err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%q: %v", test.query, err)
+        } else if query != test.expected {
+            t.Errorf("%q: expected %q, got %q", test.query, test.expected, query)
+        }
+    }
+}
+
+func TestInterpolateNoBackslashEscapes(t *testing.T) {
+    c := &Connection{}
+    c.status.Store(SERVER_STATUS_NO_BACKSLASH_ESCAPES)
+    // backslash doesn't escape quote, so second ? is placeholder
+    query, err := c.interpolate(`SELECT 'a\', ?`, []interface{}{`it's \`})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if expected := `SELECT 'a\', 'it''s \'`; query != expected {
+        t.Errorf("expected %q, got %q", expected, query)
+    }
+}
+
+func TestInterpolateCharset(t *testing.T) {
+    tests := []struct {
+        collation uint16
+        query string
+        arg string
+        expected string
+        err string
+    }{
+        {45, "SELECT 'é', ?", "€ж", "SELECT 'é', '€ж'", ""},
+        {63, "SELECT ?", "é", "SELECT 'é'", ""},
+        // latin1 is cp1252
+        {8, "SELECT 'é', ?", "€ü", "SELECT '\xe9', '\x80\xfc'", ""},
+        {8, "SELECT ?", "ж", "", "can't be sent in charset latin1"},
+        {51, "SELECT 'ж', ?", "Ё№", "SELECT '\xe6', '\xa8\xb9'", ""},
+        {51, "SELECT ?", "é", "", "can't be sent in charset cp1251"},
+        {9, "SELECT ?", "a", "", "doesn't support connection charset latin2"},
+        {1, "SELECT ?", "a", "", "unsafe with connection charset big5"},
+    }
+    for _, test := range tests {
+        c := &Connection{}
+        c.collation.Store(uint32(test.collation))
+        query, err := c.interpolate(test.query, []interface{}{test.arg})
+        if test.err != "" {
+            if err == nil || !strings.Contains(err.Error(), test.err) {
+                t.Errorf("collation %d: expected error %q, got %v", test.collation, test.err, err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("collation %d: %v", test.collation, err)
+        } else if query != test.expected {
+            t.Errorf("collation %d: expected %q, got %q", test.collation, test.expected, query)
+        }
+    }
+}
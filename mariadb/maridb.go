@@ -4,7 +4,48 @@
 //
 // Features
 //   - Goroutine safe (threading safe) - queries are served from channel.
-//   - Supports only integer data type in column definition
+//   - Column values are decoded into Go types: integers, floats, strings,
+//     []byte, time.Time and time.Duration
+//   - Exec returns Result of its own statement, so concurrent callers
+//     don't see each other's affected rows and insert ids
+//   - Client-side interpolation of ? placeholders with escaping aware of
+//     NO_BACKSLASH_ESCAPES sql mode
+//   - Named parameters :name and @name bound from map or struct
+//   - Collation negotiation with Config.Collation and SetNames, conversion
+//     of latin1, cp1251 and utf16 column values to UTF-8
+//   - Connect, read and write timeouts. After I/O error or timeout connection
+//     is broken and all queued commands fail
+//   - ParseDSN/FormatDSN in format of go-sql-driver/mysql, TLS and
+//     connection attributes
+//   - Unix domain sockets and custom dialer
+//   - Transactions with isolation levels, read-only mode, consistent
+//     snapshot and savepoints. RunInTx repeats transactions on deadlock
+//   - XA transactions with client side validation of state transitions
+//     and recovery of prepared branches
+//   - Pipelining, commands are sent without waiting for responses of previous
+//     ones, SendBatch runs statements in single round trip
+//   - QueryAsync/ExecAsync return Future, many queries can be awaited
+//     from one goroutine
+//   - Several hosts with primary/replica discovery and blacklist of failed
+//     hosts, connection follows primary after failover
+//   - Automatic reconnect restoring database, session variables, init
+//     commands and prepared statements, see TxLostError
+//   - Connection Pool with idle management, health checks and statistics
+//   - Router splitting reads to replica pools and writes to primary pool,
+//     with routing hints and round-robin, least-connections or weighted
+//     balancing
+//   - Streaming row iterator Rows, large results are not loaded into memory
+//   - Ordered Row keeping column order and duplicate column names
+//   - Scanning rows into structs with db tags: Rows.ScanStruct and QueryInto
+//   - Column metadata ColumnType for rows, statements and cursors
+//   - QueryContext/ExecContext - query running when context is cancelled
+//     is interrupted with KILL QUERY sent from side connection
+//   - Admin commands: Ping, Statistics, ProcessList, SetMultiStatements,
+//     Debug and Shutdown
+//   - Prepared statements. Parameters implementing io.Reader are streamed to
+//     server with COM_STMT_SEND_LONG_DATA
+//   - Server-side read-only cursors, rows are fetched in batches
+//   - Binlog replication client, see subpackage replication
 //
 // Information about mysql protocol
 //   - https://dev.mysql.com/doc/internals/en/client-server-protocol.html
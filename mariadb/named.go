@@ -0,0 +1,226 @@
+package mariadb
+
+import (
+    "context"
+    "fmt"
+    "reflect"
+    "strings"
+)
+
+// Rewrite :name and @name placeholders to positional ? placeholders.
+// Returns names in order of placeholders. @name which isn't bound
+// is left as user variable, @@name is system variable and :: is
+// written as single colon.
+func compileNamed(query string, noBackslashEscapes bool, bound func(name string) bool) (string, []string, error) {
+    var buf strings.Builder
+    names := []string{}
+    for i := 0; i < len(query); {
+        if j := skipLiteral(query, i, noBackslashEscapes); j > i {
+            buf.WriteString(query[i:j])
+            i = j
+            continue
+        }
+
+        ch := query[i]
+        if ch == '?' {
+            return "", nil, fmt.Errorf("named and positional placeholders can't be mixed")
+        }
+        if ch == '@' && i + 1 < len(query) && query[i + 1] == '@' {
+            j := i + 2
+            for j < len(query) && (isNameChar(query[j]) || query[j] == '.') {
+                j++
+            }
+            buf.WriteString(query[i:j])
+            i = j
+            continue
+        }
+        if ch == ':' && i + 1 < len(query) && query[i + 1] == ':' {
+            buf.WriteByte(':')
+            i += 2
+            continue
+        }
+        if ch != ':' && ch != '@' {
+            buf.WriteByte(ch)
+            i++
+            continue
+        }
+
+        j := i + 1
+        for j < len(query) && isNameChar(query[j]) {
+            j++
+        }
+        name := query[i + 1:j]
+        if name == "" || (ch == '@' && !bound(name)) {
+            buf.WriteString(query[i:j])
+            i = j
+            continue
+        }
+        buf.WriteByte('?')
+        names = append(names, name)
+        i = j
+    }
+    return buf.String(), names, nil
+}
+
+func isNameChar(ch byte) bool {
+    return ch == '_' || ch == '$' ||
+        (ch >= 'a' && ch <= 'z') ||
+        (ch >= 'A' && ch <= 'Z') ||
+        (ch >= '0' && ch <= '9')
+}
+
+// Lookup of named arguments in map with string keys
+// or struct with db tags
+type namedArgs func(name string) (interface{}, bool)
+
+func newNamedArgs(arg interface{}) (namedArgs, error) {
+    if m, ok := arg.(map[string]interface{}); ok {
+        return func(name string) (interface{}, bool) {
+            value, ok := m[name]
+            return value, ok
+        }, nil
+    }
+
+    v := reflect.ValueOf(arg)
+    for v.Kind() == reflect.Ptr && !v.IsNil() {
+        v = v.Elem()
+    }
+    switch v.Kind() {
+    case reflect.Map:
+        if v.Type().Key().Kind() != reflect.String {
+            break
+        }
+        return func(name string) (interface{}, bool) {
+            value := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+            if !value.IsValid() {
+                return nil, false
+            }
+            return value.Interface(), true
+        }, nil
+    case reflect.Struct:
+        fields := getStructFields(v.Type())
+        return func(name string) (interface{}, bool) {
+            path, ok := fields.find(name)
+            if !ok {
+                return nil, false
+            }
+            field, ok := fieldByIndexNoAlloc(v, path)
+            if !ok {
+                return nil, true
+            }
+            return field.Interface(), true
+        }, nil
+    }
+    return nil, fmt.Errorf("named arguments must be map with string keys or struct, got %T", arg)
+}
+
+// Get field by index path, returns false when embedded struct pointer is nil
+func fieldByIndexNoAlloc(v reflect.Value, path []int) (reflect.Value, bool) {
+    for i, x := range path {
+        if i > 0 && v.Kind() == reflect.Ptr {
+            if v.IsNil() {
+                return reflect.Value{}, false
+            }
+            v = v.Elem()
+        }
+        v = v.Field(x)
+    }
+    return v, true
+}
+
+// Values of named arguments in order of placeholders
+func (args namedArgs) bind(names []string) ([]interface{}, error) {
+    values := make([]interface{}, len(names))
+    for i, name := range names {
+        value, ok := args(name)
+        if !ok {
+            return nil, fmt.Errorf("missing value for named parameter '%s'", name)
+        }
+        values[i] = value
+    }
+    return values, nil
+}
+
+// Rewrite named query and bind its arguments
+func (c *Connection) bindNamed(query string, arg interface{}) (string, []interface{}, error) {
+    args, err := newNamedArgs(arg)
+    if err != nil {
+        return "", nil, err
+    }
+    noBackslashEscapes := c.status.Load() & SERVER_STATUS_NO_BACKSLASH_ESCAPES != 0
+    query, names, err := compileNamed(query, noBackslashEscapes, func(name string) bool {
+        _, ok := args(name)
+        return ok
+    })
+    if err != nil {
+        return "", nil, err
+    }
+    values, err := args.bind(names)
+    return query, values, err
+}
+
+// Run query with :name or @name placeholders bound from map or struct
+// with db tags. @name without value is left as user variable.
+//
+//	rows, err := client.QueryNamed("SELECT * FROM users WHERE name = :name",
+//	    map[string]interface{}{"name": name})
+func (c *Connection) QueryNamed(query string, arg interface{}) (QueryResultRows, error) {
+    return c.QueryNamedContext(context.Background(), query, arg)
+}
+
+func (c *Connection) QueryNamedContext(ctx context.Context, query string, arg interface{}) (QueryResultRows, error) {
+    query, args, err := c.bindNamed(query, arg)
+    if err != nil {
+        return nil, err
+    }
+    return c.QueryContext(ctx, query, args...)
+}
+
+// Run statement with :name or @name placeholders bound from map or struct with db tags
+func (c *Connection) ExecNamed(query string, arg interface{}) (Result, error) {
+    return c.ExecNamedContext(context.Background(), query, arg)
+}
+
+func (c *Connection) ExecNamedContext(ctx context.Context, query string, arg interface{}) (Result, error) {
+    query, args, err := c.bindNamed(query, arg)
+    if err != nil {
+        return Result{}, err
+    }
+    return c.ExecContext(ctx, query, args...)
+}
+
+// Prepare statement with :name or @name placeholders, use ExecuteNamed
+// to run it. Arguments aren't known yet, so every @name is placeholder
+// and user variables can't be used in statement.
+func (c *Connection) PrepareNamed(query string) (*Statement, error) {
+    noBackslashEscapes := c.status.Load() & SERVER_STATUS_NO_BACKSLASH_ESCAPES != 0
+    query, names, err := compileNamed(query, noBackslashEscapes, func(name string) bool {
+        return true
+    })
+    if err != nil {
+        return nil, err
+    }
+    stmt, err := c.Prepare(query)
+    if err != nil {
+        return nil, err
+    }
+    stmt.names = names
+    return stmt, nil
+}
+
+// Execute statement prepared with PrepareNamed. Arguments are bound
+// from map or struct with db tags.
+func (s *Statement) ExecuteNamed(arg interface{}) (QueryResultRows, error) {
+    if s.names == nil {
+        return nil, fmt.Errorf("statement isn't prepared with PrepareNamed")
+    }
+    args, err := newNamedArgs(arg)
+    if err != nil {
+        return nil, err
+    }
+    values, err := args.bind(s.names)
+    if err != nil {
+        return nil, err
+    }
+    return s.Execute(values...)
+}
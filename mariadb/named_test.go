@@ -0,0 +1,105 @@
+package mariadb
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestBindNamed(t *testing.T) {
+    args := map[string]interface{}{"a": 1, "b": "x", "user": nil}
+    tests := []struct {
+        query string
+        noBackslashEscapes bool
+        expected string
+        values []interface{}
+        err string
+    }{
+        {query: "SELECT :a, @b, :a", expected: "SELECT ?, ?, ?", values: []interface{}{1, "x", 1}},
+        {query: "SELECT * FROM t WHERE id=:a", expected: "SELECT * FROM t WHERE id=?", values: []interface{}{1}},
+        {query: "SELECT ':a', \":a\", :b", expected: "SELECT ':a', \":a\", ?", values: []interface{}{"x"}},
+        {query: "SELECT 'it''s :a', :b", expected: "SELECT 'it''s :a', ?", values: []interface{}{"x"}},
+        {query: `SELECT 'a\' :a', :b`, expected: `SELECT 'a\' :a', ?`, values: []interface{}{"x"}},
+        // backslash doesn't escape quote, so :a is outside of literal
+        {query: `SELECT 'a\', :a`, noBackslashEscapes: true, expected: `SELECT 'a\', ?`, values: []interface{}{1}},
+        {query: "SELECT `:a`, `@b` FROM t WHERE x = :b", expected: "SELECT `:a`, `@b` FROM t WHERE x = ?", values: []interface{}{"x"}},
+        {query: "SELECT :a -- :b\n", expected: "SELECT ? -- :b\n", values: []interface{}{1}},
+        {query: "SELECT :a # :b", expected: "SELECT ? # :b", values: []interface{}{1}},
+        {query: "SELECT /* :b @b */ :a", expected: "SELECT /* :b @b */ ?", values: []interface{}{1}},
+        {query: "SELECT '10::00', a::b, :a", expected: "SELECT '10::00', a:b, ?", values: []interface{}{1}},
+        {query: "SET @c := :a", expected: "SET @c := ?", values: []interface{}{1}},
+        {query: "SELECT @@sql_mode, @@session.time_zone, @c", expected: "SELECT @@sql_mode, @@session.time_zone, @c", values: []interface{}{}},
+        {query: "SELECT @user", expected: "SELECT ?", values: []interface{}{nil}},
+        {query: "SELECT :, @", expected: "SELECT :, @", values: []interface{}{}},
+        {query: "SELECT :a, :c", err: "missing value for named parameter 'c'"},
+        {query: "SELECT :a, ?", err: "can't be mixed"},
+    }
+    for _, test := range tests {
+        c := &Connection{}
+        if test.noBackslashEscapes {
+            c.status.Store(SERVER_STATUS_NO_BACKSLASH_ESCAPES)
+        }
+        query, values, err := c.bindNamed(test.query, args)
+        if test.err != "" {
+            if err == nil || !strings.Contains(err.Error(), test.err) {
+                t.Errorf("%q: expected error %q, got %v", test.query, test.err, err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%q: %v", test.query, err)
+            continue
+        }
+        if query != test.expected || !reflect.DeepEqual(values, test.values) {
+            t.Errorf("%q: expected %q %v, got %q %v", test.query, test.expected, test.values, query, values)
+        }
+    }
+}
+
+func TestBindNamedArgs(t *testing.T) {
+    type Audit struct {
+        Author string `db:"author"`
+    }
+    type row struct {
+        *Audit
+        Id int `db:"id"`
+        Name string
+        Skipped string `db:"-"`
+    }
+    tests := []struct {
+        name string
+        arg interface{}
+        values []interface{}
+        err string
+    }{
+        {"map", map[string]interface{}{"id": 1, "name": "a", "author": "b"}, []interface{}{1, "a", "b"}, ""},
+        {"typed map", map[string]string{"id": "1", "name": "a", "author": "b"}, []interface{}{"1", "a", "b"}, ""},
+        {"struct", row{Audit: &Audit{"b"}, Id: 1, Name: "a"}, []interface{}{1, "a", "b"}, ""},
+        {"struct pointer", &row{Audit: &Audit{"b"}, Id: 1, Name: "a"}, []interface{}{1, "a", "b"}, ""},
+        // field of nil embedded struct is NULL
+        {"nil embedded struct", row{Id: 1, Name: "a"}, []interface{}{1, "a", nil}, ""},
+        {"missing value", map[string]interface{}{"id": 1}, nil, "missing value for named parameter 'name'"},
+        {"unsupported type", 1, nil, "must be map with string keys or struct"},
+        {"map without string keys", map[int]interface{}{1: 1}, nil, "must be map with string keys or struct"},
+    }
+    for _, test := range tests {
+        c := &Connection{}
+        _, values, err := c.bindNamed("SELECT :id, :name, :author", test.arg)
+        if test.err != "" {
+            if err == nil || !strings.Contains(err.Error(), test.err) {
+                t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: %v", test.name, err)
+        } else if !reflect.DeepEqual(values, test.values) {
+            t.Errorf("%s: expected %v, got %v", test.name, test.values, values)
+        }
+    }
+
+    c := &Connection{}
+    if _, _, err := c.bindNamed("SELECT :Skipped", row{Skipped: "a"}); err == nil {
+        t.Error("field tagged with db:\"-\" is bound")
+    }
+}
@@ -5,6 +5,10 @@ import (
     "bytes"
     "crypto/sha1"
     "fmt"
+    "io"
+    "math"
+    "time"
+    "github.com/vasflam/lab-mysql-connector/mariadb/capabilities"
 )
 
 const (
@@ -14,6 +18,10 @@ const (
     packetTypeERR = 0xff
 )
 
+// Longest payload of one packet, longer payloads are split
+// See https://mariadb.com/kb/en/0-packet/#packet-splitting
+const MAX_PACKET_PAYLOAD = 0xffffff
+
 type packetDirection int
 const (
     incomingPacket packetDirection = iota
@@ -63,6 +71,11 @@ func (p Packet) payloadLength() int {
     return length
 }
 
+// Number of bytes left to read
+func (p *Packet) remaining() int {
+    return len(p.payload) - p.pos
+}
+
 func (p *Packet) resetPos() {
     p.pos = 0
 }
@@ -76,7 +89,7 @@ func (p *Packet) peek() byte {
 }
 
 func (p *Packet) peekAt(pos int) byte {
-    if pos <= len(p.payload) {
+    if pos < len(p.payload) {
         return p.payload[pos]
     }
     return 0
@@ -125,12 +138,13 @@ func (p *Packet) readUInt16() uint16 {
 }
 
 func (p *Packet) readInt24() int32 {
-    return int32(p.readUInt32())
+    return int32(p.readUInt24()<<8) >> 8
 }
 
 func (p *Packet) readUInt24() uint32 {
-    normalized := append(p.payload[p.pos:p.pos+3], 0)
-    v := binary.LittleEndian.Uint32(normalized)
+    b := p.payload[p.pos:p.pos+3]
+    v := uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+    p.pos += 3
     return v
 }
 
@@ -168,33 +182,37 @@ func (p *Packet) readUInt64() uint64 {
 }
 
 func (p *Packet) readBytesLengthEncoded() []byte {
-    b := int(p.readUInt8())
+    b := p.readUIntLengthEncoded()
     return p.readBytes(b)
 }
 
+// See https://mariadb.com/kb/en/protocol-data-types/#length-encoded-integers
 func (p *Packet) readUIntLengthEncoded() int {
     length := int(p.readUInt8())
-    if length < 0xfb {
-        return length
-    } else if length < 65536 {
+    switch length {
+    case 0xfb:
+        return 0
+    case 0xfc:
         return int(p.readUInt16())
-    } else if length < 16777216 {
+    case 0xfd:
         return int(p.readUInt24())
-    } else {
+    case 0xfe:
         return int(p.readUInt64())
     }
+    return length
 }
 
 func (p *Packet) readStringLengthEncoded() string {
-    length := int(p.readUInt8())
+    length := p.readUIntLengthEncoded()
     return string(p.readBytes(length))
 }
 
 func (p *Packet) readStringLengthEncodedNULLABLE() (string, bool) {
     if p.peek() == 0xfb {
+        p.skip(1)
         return "", true
     }
-    length := int(p.readUInt8())
+    length := p.readUIntLengthEncoded()
     return string(p.readBytes(length)), false
 }
 
@@ -260,12 +278,17 @@ func (p *Packet) writeEmptyHeader() {
     p.writeHeader([]byte{0, 0, 0, 0})
 }
 
+// Write payload length to header. Length of payload which doesn't fit
+// in one packet is written as MAX_PACKET_PAYLOAD, see wire().
 func (p *Packet) updateHeader() {
     if !p.hasHeader {
         p.writeEmptyHeader()
     }
 
     length := len(p.payload) - 4
+    if length > MAX_PACKET_PAYLOAD {
+        length = MAX_PACKET_PAYLOAD
+    }
     temp := Packet{}
     temp.writeUInt24(uint32(length))
     header := temp.bytes()
@@ -277,6 +300,31 @@ func (p *Packet) updateHeader() {
     }
 }
 
+// Bytes sent to server. Payload of MAX_PACKET_PAYLOAD bytes or longer
+// is split into packets with increasing sequence, last of them is
+// shorter than MAX_PACKET_PAYLOAD and can be empty.
+func (p Packet) wire() []byte {
+    if !p.hasHeader || p.payloadLength() < MAX_PACKET_PAYLOAD {
+        return p.payload
+    }
+    payload := p.payload[4:]
+    sequence := p.getSequence()
+    buf := make([]byte, 0, len(payload) + (len(payload) / MAX_PACKET_PAYLOAD + 1) * 4)
+    for {
+        n := len(payload)
+        if n > MAX_PACKET_PAYLOAD {
+            n = MAX_PACKET_PAYLOAD
+        }
+        buf = append(buf, byte(n), byte(n >> 8), byte(n >> 16), sequence)
+        buf = append(buf, payload[:n]...)
+        payload = payload[n:]
+        sequence++
+        if n < MAX_PACKET_PAYLOAD {
+            return buf
+        }
+    }
+}
+
 func (p *Packet) setSequence(i uint8) {
     if p.hasHeader {
         p.payload[3] = i
@@ -308,10 +356,12 @@ func (p Packet) restorePos() {
     }
 }
 
+// Matches both EOF packet and OK packet with 0xfe header, which is sent
+// instead of EOF when DEPRECATE_EOF capability is set
 func (p Packet) isEOF() bool {
     return p.direction == incomingPacket &&
         p.peekAt(4) == packetTypeEOF &&
-        p.payloadLength() < 9
+        p.payloadLength() < MAX_PACKET_PAYLOAD
 }
 
 func (p Packet) isERR() bool {
@@ -321,13 +371,24 @@ func (p Packet) isERR() bool {
 func (p Packet) isOK() bool {
     return p.direction == incomingPacket &&
         p.peekAt(4) == packetTypeOK &&
-        p.payloadLength() < 0xffffff
+        p.payloadLength() < MAX_PACKET_PAYLOAD
 }
 
 func (p Packet) isLOCALINFILE() bool {
     return p.direction == incomingPacket && p.peekAt(4) == packetTypeLOCALINFILE
 }
 
+// Error returned by server.
+// See https://mariadb.com/kb/en/mariadb-error-codes/
+type MysqlError struct {
+    Code int
+    Message string
+}
+
+func (e *MysqlError) Error() string {
+    return fmt.Sprintf("mysql error [%d]: %s", e.Code, e.Message)
+}
+
 // See https://mariadb.com/kb/en/err_packet/
 type errorPacket struct {
     *Packet
@@ -364,6 +425,107 @@ func (p *errorPacket) error() string {
     return message
 }
 
+// See https://mariadb.com/kb/en/ok_packet/
+type okPacket struct {
+    affectedRows uint64
+    lastInsertId uint64
+    status uint16
+    warnings uint16
+    info string
+    // current schema when it was changed by statement
+    schema string
+}
+
+// Parse OK packet or EOF packet. When DEPRECATE_EOF capability is set
+// result set is terminated with OK packet having 0xfe header.
+func parseOkPacket(packet *Packet, clientCapabilities uint64) *okPacket {
+    ok := &okPacket{}
+    packet.resetPos()
+    packet.skip(4)
+    header := packet.readUInt8()
+    if header == packetTypeEOF && clientCapabilities & capabilities.DEPRECATE_EOF == 0 {
+        ok.warnings = packet.readUInt16()
+        ok.status = packet.readUInt16()
+        packet.resetPos()
+        return ok
+    }
+
+    ok.affectedRows = uint64(packet.readUIntLengthEncoded())
+    ok.lastInsertId = uint64(packet.readUIntLengthEncoded())
+    ok.status = packet.readUInt16()
+    ok.warnings = packet.readUInt16()
+    if packet.pos < packet.length() {
+        if clientCapabilities & capabilities.SESSION_TRACK != 0 {
+            ok.info = packet.readStringLengthEncoded()
+            if ok.status & SERVER_SESSION_STATE_CHANGED != 0 && packet.pos < packet.length() {
+                parseSessionState(ok, packet)
+            }
+        } else {
+            ok.info = string(packet.readBytesRest())
+        }
+    }
+    packet.resetPos()
+    return ok
+}
+
+// See https://mariadb.com/kb/en/ok_packet/#session-change-type
+const SESSION_TRACK_SYSTEM_VARIABLES = 0
+const SESSION_TRACK_SCHEMA = 1
+const SESSION_TRACK_STATE_CHANGE = 2
+const SESSION_TRACK_GTIDS = 3
+const SESSION_TRACK_TRANSACTION_CHARACTERISTICS = 4
+const SESSION_TRACK_TRANSACTION_STATE = 5
+
+func parseSessionState(ok *okPacket, packet *Packet) {
+    end := packet.pos + packet.readUIntLengthEncoded()
+    for packet.pos < end && packet.pos < packet.length() {
+        kind := packet.readUInt8()
+        length := packet.readUIntLengthEncoded()
+        next := packet.pos + length
+        if kind == SESSION_TRACK_SCHEMA {
+            ok.schema = packet.readStringLengthEncoded()
+        }
+        packet.pos = next
+    }
+}
+
+// See https://mariadb.com/kb/en/result-set-packets/#column-definition-packet
+func parseColumnDefinition(packet *Packet, clientCapabilities uint64) tableColumn {
+    packet.resetPos()
+    packet.skip(4)
+    catalog := packet.readStringLengthEncoded()
+    schema := packet.readStringLengthEncoded()
+    tableAlias := packet.readStringLengthEncoded()
+    table := packet.readStringLengthEncoded()
+    columnAlias := packet.readStringLengthEncoded()
+    name := packet.readStringLengthEncoded()
+    if clientCapabilities & capabilities.MARIADB_CLIENT_EXTENDED_TYPE_INFO != 0 {
+        count := packet.readUIntLengthEncoded()
+        for i := 0; i < count; i++ {
+            _ = packet.readUInt8() // type
+            _ = packet.readStringLengthEncoded() // value
+        }
+    }
+
+    column := tableColumn{
+        catalog: catalog,
+        schema: schema,
+        tableAlias: tableAlias,
+        table: table,
+        orgName: name,
+        name: columnAlias,
+        fixedFields: packet.readUIntLengthEncoded(),
+        charset: packet.readUInt16(),
+        maxSize: packet.readUInt32(),
+        kind: packet.readUInt8(),
+        flag: packet.readUInt16(),
+        decimals: packet.readUInt8(),
+        unused: packet.readUInt16(),
+    }
+    packet.resetPos()
+    return column
+}
+
 func createQuitPacket() *Packet {
     packet := &Packet{}
     packet.writeUInt8(COM_QUIT)
@@ -399,3 +561,239 @@ func createPingPacket() *Packet {
     packet.direction = outgoingPacket
     return packet
 }
+
+// Packet of command without arguments
+func createCommandPacket(command uint8) *Packet {
+    packet := &Packet{}
+    packet.writeUInt8(command)
+    packet.updateHeader()
+    packet.direction = outgoingPacket
+    return packet
+}
+
+// See https://mariadb.com/kb/en/com_set_option/
+func createSetOptionPacket(option uint16) *Packet {
+    packet := &Packet{}
+    packet.writeUInt8(COM_SET_OPTION)
+    packet.writeUInt16(option)
+    packet.updateHeader()
+    packet.direction = outgoingPacket
+    return packet
+}
+
+// See https://mariadb.com/kb/en/com_shutdown/
+func createShutdownPacket() *Packet {
+    packet := &Packet{}
+    packet.writeUInt8(COM_SHUTDOWN)
+    packet.writeUInt8(0) // SHUTDOWN_DEFAULT
+    packet.updateHeader()
+    packet.direction = outgoingPacket
+    return packet
+}
+
+func createStmtPreparePacket(query string) *Packet {
+    packet := &Packet{}
+    packet.writeUInt8(COM_STMT_PREPARE)
+    packet.writeBytes([]byte(query))
+    packet.updateHeader()
+    packet.direction = outgoingPacket
+    return packet
+}
+
+// See https://mariadb.com/kb/en/com_stmt_execute/
+func createStmtExecutePacket(id uint32, flags uint8, args []interface{}) (*Packet, error) {
+    packet := &Packet{}
+    packet.writeUInt8(COM_STMT_EXECUTE)
+    packet.writeUInt32(id)
+    packet.writeUInt8(flags)
+    packet.writeUInt32(1) // iteration count
+
+    if len(args) > 0 {
+        nullBitmap := make([]byte, (len(args) + 7) / 8)
+        types := &Packet{}
+        values := &Packet{}
+        for i, arg := range args {
+            if arg == nil {
+                nullBitmap[i / 8] |= 1 << (i % 8)
+            }
+            kind, unsigned, err := values.writeBinaryValue(arg)
+            if err != nil {
+                return nil, fmt.Errorf("argument %d: %w", i, err)
+            }
+            types.writeUInt8(kind)
+            if unsigned {
+                types.writeUInt8(0x80)
+            } else {
+                types.writeUInt8(0)
+            }
+        }
+        packet.writeBytes(nullBitmap)
+        packet.writeUInt8(1) // send types to server
+        packet.writeBytes(types.bytes())
+        packet.writeBytes(values.bytes())
+    }
+
+    packet.updateHeader()
+    packet.direction = outgoingPacket
+    return packet, nil
+}
+
+// Write parameter value in binary protocol encoding. Returns type of value.
+// Values of io.Reader are sent with COM_STMT_SEND_LONG_DATA, so only type is returned.
+func (p *Packet) writeBinaryValue(arg interface{}) (uint8, bool, error) {
+    switch v := arg.(type) {
+    case nil:
+        return MYSQL_TYPE_NULL, false, nil
+    case bool:
+        if v {
+            p.writeUInt8(1)
+        } else {
+            p.writeUInt8(0)
+        }
+        return MYSQL_TYPE_TINY, false, nil
+    case int:
+        p.writeUInt64(uint64(v))
+        return MYSQL_TYPE_LONGLONG, false, nil
+    case int8:
+        p.writeUInt64(uint64(v))
+        return MYSQL_TYPE_LONGLONG, false, nil
+    case int16:
+        p.writeUInt64(uint64(v))
+        return MYSQL_TYPE_LONGLONG, false, nil
+    case int32:
+        p.writeUInt64(uint64(v))
+        return MYSQL_TYPE_LONGLONG, false, nil
+    case int64:
+        p.writeUInt64(uint64(v))
+        return MYSQL_TYPE_LONGLONG, false, nil
+    case uint:
+        p.writeUInt64(uint64(v))
+        return MYSQL_TYPE_LONGLONG, true, nil
+    case uint8:
+        p.writeUInt64(uint64(v))
+        return MYSQL_TYPE_LONGLONG, true, nil
+    case uint16:
+        p.writeUInt64(uint64(v))
+        return MYSQL_TYPE_LONGLONG, true, nil
+    case uint32:
+        p.writeUInt64(uint64(v))
+        return MYSQL_TYPE_LONGLONG, true, nil
+    case uint64:
+        p.writeUInt64(v)
+        return MYSQL_TYPE_LONGLONG, true, nil
+    case float32:
+        p.writeUInt32(math.Float32bits(v))
+        return MYSQL_TYPE_FLOAT, false, nil
+    case float64:
+        p.writeUInt64(math.Float64bits(v))
+        return MYSQL_TYPE_DOUBLE, false, nil
+    case string:
+        p.writeLengthEncoded(uint64(len(v)))
+        p.writeBytes([]byte(v))
+        return MYSQL_TYPE_VAR_STRING, false, nil
+    case []byte:
+        p.writeLengthEncoded(uint64(len(v)))
+        p.writeBytes(v)
+        return MYSQL_TYPE_BLOB, false, nil
+    case time.Time:
+        // values are read back in UTC, see readBinaryDateTime
+        v = v.UTC()
+        p.writeUInt8(11)
+        p.writeUInt16(uint16(v.Year()))
+        p.writeUInt8(uint8(v.Month()))
+        p.writeUInt8(uint8(v.Day()))
+        p.writeUInt8(uint8(v.Hour()))
+        p.writeUInt8(uint8(v.Minute()))
+        p.writeUInt8(uint8(v.Second()))
+        p.writeUInt32(uint32(v.Nanosecond() / 1000))
+        return MYSQL_TYPE_DATETIME, false, nil
+    case time.Duration:
+        negative := uint8(0)
+        if v < 0 {
+            negative = 1
+            v = -v
+        }
+        p.writeUInt8(12)
+        p.writeUInt8(negative)
+        p.writeUInt32(uint32(v / (24 * time.Hour)))
+        p.writeUInt8(uint8(v / time.Hour % 24))
+        p.writeUInt8(uint8(v / time.Minute % 60))
+        p.writeUInt8(uint8(v / time.Second % 60))
+        p.writeUInt32(uint32(v / time.Microsecond % 1000000))
+        return MYSQL_TYPE_TIME, false, nil
+    case io.Reader:
+        return MYSQL_TYPE_LONG_BLOB, false, nil
+    }
+    return 0, false, fmt.Errorf("unsupported type %T", arg)
+}
+
+// See https://mariadb.com/kb/en/com_stmt_send_long_data/
+func createStmtSendLongDataPacket(id uint32, param uint16, data []byte) *Packet {
+    packet := &Packet{}
+    packet.writeUInt8(COM_STMT_SEND_LONG_DATA)
+    packet.writeUInt32(id)
+    packet.writeUInt16(param)
+    packet.writeBytes(data)
+    packet.updateHeader()
+    packet.direction = outgoingPacket
+    return packet
+}
+
+func createStmtResetPacket(id uint32) *Packet {
+    packet := &Packet{}
+    packet.writeUInt8(COM_STMT_RESET)
+    packet.writeUInt32(id)
+    packet.updateHeader()
+    packet.direction = outgoingPacket
+    return packet
+}
+
+func createStmtClosePacket(id uint32) *Packet {
+    packet := &Packet{}
+    packet.writeUInt8(COM_STMT_CLOSE)
+    packet.writeUInt32(id)
+    packet.updateHeader()
+    packet.direction = outgoingPacket
+    return packet
+}
+
+// See https://mariadb.com/kb/en/com_register_slave/
+func createRegisterSlavePacket(serverId uint32, hostname string, port uint16) *Packet {
+    packet := &Packet{}
+    packet.writeUInt8(COM_REGISTER_SLAVE)
+    packet.writeUInt32(serverId)
+    packet.writeUInt8(uint8(len(hostname)))
+    packet.writeBytes([]byte(hostname))
+    packet.writeUInt8(0) // username
+    packet.writeUInt8(0) // password
+    packet.writeUInt16(port)
+    packet.writeUInt32(0) // replication rank
+    packet.writeUInt32(0) // master id
+    packet.updateHeader()
+    packet.direction = outgoingPacket
+    return packet
+}
+
+// See https://mariadb.com/kb/en/com_binlog_dump/
+func createBinlogDumpPacket(serverId uint32, file string, position uint32, flags uint16) *Packet {
+    packet := &Packet{}
+    packet.writeUInt8(COM_BINLOG_DUMP)
+    packet.writeUInt32(position)
+    packet.writeUInt16(flags)
+    packet.writeUInt32(serverId)
+    packet.writeBytes([]byte(file))
+    packet.updateHeader()
+    packet.direction = outgoingPacket
+    return packet
+}
+
+// See https://mariadb.com/kb/en/com_stmt_fetch/
+func createStmtFetchPacket(id uint32, rows uint32) *Packet {
+    packet := &Packet{}
+    packet.writeUInt8(COM_STMT_FETCH)
+    packet.writeUInt32(id)
+    packet.writeUInt32(rows)
+    packet.updateHeader()
+    packet.direction = outgoingPacket
+    return packet
+}
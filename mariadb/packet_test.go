@@ -0,0 +1,55 @@
+package mariadb
+
+import (
+    "bytes"
+    "testing"
+)
+
+func TestPacketWire(t *testing.T) {
+    tests := []struct {
+        length int
+        packets []int
+    }{
+        {0, []int{0}},
+        {10, []int{10}},
+        {MAX_PACKET_PAYLOAD - 1, []int{MAX_PACKET_PAYLOAD - 1}},
+        // packet of maximal length is followed by empty packet
+        {MAX_PACKET_PAYLOAD, []int{MAX_PACKET_PAYLOAD, 0}},
+        {MAX_PACKET_PAYLOAD + 10, []int{MAX_PACKET_PAYLOAD, 10}},
+        {2 * MAX_PACKET_PAYLOAD + 1, []int{MAX_PACKET_PAYLOAD, MAX_PACKET_PAYLOAD, 1}},
+    }
+    for _, test := range tests {
+        payload := make([]byte, test.length)
+        for i := range payload {
+            payload[i] = byte(i)
+        }
+        packet := &Packet{}
+        packet.writeBytes(payload)
+        packet.updateHeader()
+        packet.setSequence(3)
+
+        buf := packet.wire()
+        joined := []byte{}
+        for i, length := range test.packets {
+            if len(buf) < 4 {
+                t.Fatalf("payload %d: packet %d is missing", test.length, i)
+            }
+            size := int(buf[0]) | int(buf[1]) << 8 | int(buf[2]) << 16
+            if size != length || buf[3] != byte(3 + i) {
+                t.Errorf("payload %d: packet %d has length %d and sequence %d, expected %d and %d",
+                    test.length, i, size, buf[3], length, 3 + i)
+            }
+            if len(buf) < 4 + size {
+                t.Fatalf("payload %d: packet %d is truncated", test.length, i)
+            }
+            joined = append(joined, buf[4:4 + size]...)
+            buf = buf[4 + size:]
+        }
+        if len(buf) != 0 {
+            t.Errorf("payload %d: %d unexpected bytes after packets", test.length, len(buf))
+        }
+        if !bytes.Equal(joined, payload) {
+            t.Errorf("payload %d: packets don't contain payload", test.length)
+        }
+    }
+}
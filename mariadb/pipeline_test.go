@@ -0,0 +1,435 @@
+package mariadb
+
+import (
+    "context"
+    "fmt"
+    "net"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// Server answering query with result set of its text. BLOCK queries wait
+// until release is closed or query is killed, DROP ends connection.
+type pipelineServer struct {
+    *testServer
+    release chan struct{}
+    unblockOnce sync.Once
+    killed chan struct{}
+}
+
+func newPipelineServer(t *testing.T) *pipelineServer {
+    s := &pipelineServer{release: make(chan struct{}), killed: make(chan struct{}, 1)}
+    s.testServer = newTestServer(t, func(conn net.Conn, cmd []byte) [][]byte {
+        query := commandQuery(cmd)
+        switch {
+        case strings.HasPrefix(query, "BLOCK"):
+            select {
+            case <-s.release:
+            case <-s.killed:
+                return [][]byte{testErr(1317, "Query execution was interrupted")}
+            }
+        case strings.HasPrefix(query, "KILL QUERY"):
+            s.killed <- struct{}{}
+            return [][]byte{testOK(0, 0, SERVER_STATUS_AUTOCOMMIT)}
+        case query == "DROP":
+            // connection is half closed, so responses which are
+            // already sent reach client before end of stream
+            conn.(*net.TCPConn).CloseWrite()
+            return nil
+        }
+        return testResultSet(SERVER_STATUS_AUTOCOMMIT, []string{"q"}, []interface{}{query})
+    })
+    return s
+}
+
+// Answer BLOCK queries
+func (s *pipelineServer) unblock() {
+    s.unblockOnce.Do(func() {
+        close(s.release)
+    })
+}
+
+// Connection closed when test ends, blocked queries are released
+// before, so failed test doesn't hang in Close
+func (s *pipelineServer) connect(t *testing.T, config Config) *Connection {
+    conn, err := Connect(config, context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(conn.Close)
+    t.Cleanup(s.unblock)
+    return conn
+}
+
+// Wait until server receives query
+func (s *pipelineServer) waitQuery(t *testing.T, query string) {
+    t.Helper()
+    waitFor(t, func() bool {
+        for _, q := range s.queries() {
+            if q == query {
+                return true
+            }
+        }
+        return false
+    })
+}
+
+// Check that rows are result of query
+func checkEcho(t *testing.T, query string, rows QueryResultRows, err error) {
+    t.Helper()
+    if err != nil {
+        t.Errorf("%s: %v", query, err)
+        return
+    }
+    if len(rows) != 1 || rows[0]["q"] != query {
+        t.Errorf("%s: got result %v", query, rows)
+    }
+}
+
+type queryResult struct {
+    rows QueryResultRows
+    err error
+}
+
+// Run query in goroutine, commands without cancellable context are pipelined
+func goQuery(conn *Connection, query string) chan queryResult {
+    result := make(chan queryResult, 1)
+    go func() {
+        rows, err := conn.Query(query)
+        result <- queryResult{rows, err}
+    }()
+    return result
+}
+
+// Wait for result of query, fail test when it hangs
+func waitResult(t *testing.T, result chan queryResult) queryResult {
+    t.Helper()
+    select {
+    case r := <-result:
+        return r
+    case <-time.After(time.Second):
+        t.Fatal("query isn't finished")
+    }
+    return queryResult{}
+}
+
+func TestPipelineInterleavedCommands(t *testing.T) {
+    s := newPipelineServer(t)
+    conn := s.connect(t, s.config())
+
+    blocked := goQuery(conn, "BLOCK")
+    s.waitQuery(t, "BLOCK")
+    second := goQuery(conn, "SELECT 2")
+    waitFor(t, func() bool {
+        return len(conn.pending) == 1
+    })
+    // sent without waiting for response of blocked query
+    third := goQuery(conn, "SELECT 3")
+    waitFor(t, func() bool {
+        return len(conn.pending) == 2
+    })
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    exclusive := make(chan error)
+    go func() {
+        rows, err := conn.QueryContext(ctx, "SELECT 4")
+        checkEcho(t, "SELECT 4", rows, err)
+        exclusive <- err
+    }()
+    time.Sleep(20 * time.Millisecond)
+    if n := len(conn.pending); n != 2 {
+        t.Errorf("command which can be killed is sent before responses are read, %d pending", n)
+    }
+    s.unblock()
+
+    for query, result := range map[string]chan queryResult{"BLOCK": blocked, "SELECT 2": second, "SELECT 3": third} {
+        r := waitResult(t, result)
+        checkEcho(t, query, r.rows, r.err)
+    }
+    <-exclusive
+
+    // commands of concurrent goroutines are matched to own responses
+    var wg sync.WaitGroup
+    for i := 0; i < 30; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            query := fmt.Sprintf("SELECT %d", i)
+            switch i % 3 {
+            case 0:
+                rows, err := conn.Query(query)
+                checkEcho(t, query, rows, err)
+            case 1:
+                batch := &Batch{}
+                batch.Queue(query)
+                batch.Queue(query + ", 1")
+                results, err := conn.SendBatch(context.Background(), batch)
+                if err != nil {
+                    t.Errorf("%s: %v", query, err)
+                    return
+                }
+                checkEcho(t, query, results[0].Rows, results[0].Err)
+                checkEcho(t, query + ", 1", results[1].Rows, results[1].Err)
+            case 2:
+                rows, err := conn.QueryContext(ctx, query)
+                checkEcho(t, query, rows, err)
+            }
+        }(i)
+    }
+    wg.Wait()
+}
+
+func TestPipelineCancelBeforeSend(t *testing.T) {
+    s := newPipelineServer(t)
+    conn := s.connect(t, s.config())
+
+    // command which can be killed holds queue until its response is read
+    blockCtx, cancelBlock := context.WithCancel(context.Background())
+    defer cancelBlock()
+    blocked := make(chan error)
+    go func() {
+        _, err := conn.QueryContext(blockCtx, "BLOCK")
+        blocked <- err
+    }()
+    s.waitQuery(t, "BLOCK")
+
+    ctx, cancel := context.WithCancel(context.Background())
+    batch := &Batch{}
+    batch.Queue("SELECT 1")
+    batch.Queue("SELECT 2")
+    batchErr := make(chan error)
+    go func() {
+        results, err := conn.SendBatch(ctx, batch)
+        for _, result := range results {
+            if result.Err != context.Canceled {
+                t.Errorf("expected cancelled statement, got %v", result.Err)
+            }
+        }
+        batchErr <- err
+    }()
+    cancel()
+
+    select {
+    case err := <-batchErr:
+        if err != context.Canceled {
+            t.Errorf("expected cancelled batch, got %v", err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("batch waits for blocked command")
+    }
+
+    s.unblock()
+    if err := <-blocked; err != nil {
+        t.Fatal(err)
+    }
+    rows, err := conn.Query("SELECT 4")
+    checkEcho(t, "SELECT 4", rows, err)
+    for _, query := range s.queries() {
+        if query == "SELECT 1" || query == "SELECT 2" {
+            t.Errorf("cancelled command %q is sent", query)
+        }
+    }
+}
+
+func TestPipelineCancelledBatchIsNotKilled(t *testing.T) {
+    s := newPipelineServer(t)
+    conn := s.connect(t, s.config())
+
+    ctx, cancel := context.WithCancel(context.Background())
+    batch := &Batch{}
+    batch.Queue("BLOCK")
+    batch.Queue("SELECT 2")
+    batchErr := make(chan error)
+    go func() {
+        _, err := conn.SendBatch(ctx, batch)
+        batchErr <- err
+    }()
+    s.waitQuery(t, "BLOCK")
+    waitFor(t, func() bool {
+        return len(conn.pending) == 1
+    })
+    cancel()
+    select {
+    case err := <-batchErr:
+        if err != context.Canceled {
+            t.Errorf("expected cancelled batch, got %v", err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("batch isn't finished")
+    }
+    time.Sleep(20 * time.Millisecond)
+    // KILL QUERY would be sent from side connection
+    if n := s.connections(); n != 1 {
+        t.Errorf("expected 1 connection, got %d", n)
+    }
+
+    s.unblock()
+    rows, err := conn.Query("SELECT 3")
+    checkEcho(t, "SELECT 3", rows, err)
+    if queries := s.queries(); queries[1] != "SELECT 2" {
+        t.Errorf("sent statement of batch isn't run: %q", queries)
+    }
+}
+
+func TestPipelineKillDoesNotHitPipelinedCommand(t *testing.T) {
+    s := newPipelineServer(t)
+    conn := s.connect(t, s.config())
+
+    ctx, cancel := context.WithCancel(context.Background())
+    killed := make(chan error)
+    go func() {
+        _, err := conn.QueryContext(ctx, "BLOCK")
+        killed <- err
+    }()
+    s.waitQuery(t, "BLOCK")
+    next := goQuery(conn, "SELECT 2")
+    cancel()
+    if err := <-killed; err != context.Canceled {
+        t.Errorf("expected cancelled query, got %v", err)
+    }
+    r := waitResult(t, next)
+    checkEcho(t, "SELECT 2", r.rows, r.err)
+    s.waitQuery(t, fmt.Sprintf("KILL QUERY %d", conn.info.connectionId))
+}
+
+func TestPipelineConnectionFailure(t *testing.T) {
+    s := newPipelineServer(t)
+    conn := s.connect(t, s.config())
+
+    first := goQuery(conn, "BLOCK")
+    s.waitQuery(t, "BLOCK")
+    drop := goQuery(conn, "DROP")
+    waitFor(t, func() bool {
+        return len(conn.pending) == 1
+    })
+    batch := &Batch{}
+    batch.Queue("SELECT 3")
+    batch.Queue("SELECT 4")
+    batchErr := make(chan error)
+    go func() {
+        _, err := conn.SendBatch(context.Background(), batch)
+        batchErr <- err
+    }()
+    waitFor(t, func() bool {
+        return len(conn.pending) == 3
+    })
+    last := goQuery(conn, "SELECT 5")
+    s.unblock()
+
+    r := waitResult(t, first)
+    checkEcho(t, "BLOCK", r.rows, r.err)
+    if r := waitResult(t, drop); r.err == nil {
+        t.Error("expected error of command which lost connection")
+    }
+    select {
+    case err := <-batchErr:
+        if err == nil {
+            t.Error("expected error of batch sent before failure")
+        }
+    case <-time.After(time.Second):
+        t.Fatal("batch isn't finished")
+    }
+    if r := waitResult(t, last); r.err == nil {
+        t.Error("expected error of command sent after failure")
+    }
+    _, err := conn.Query("SELECT 6")
+    if err == nil || !strings.Contains(err.Error(), "connection is broken") {
+        t.Errorf("expected broken connection error, got %v", err)
+    }
+}
+
+func TestPipelineConnectionFailureWithAutoReconnect(t *testing.T) {
+    s := newPipelineServer(t)
+    config := s.config()
+    config.AutoReconnect = true
+    conn := s.connect(t, config)
+
+    first := goQuery(conn, "BLOCK")
+    s.waitQuery(t, "BLOCK")
+    drop := goQuery(conn, "DROP")
+    waitFor(t, func() bool {
+        return len(conn.pending) == 1
+    })
+    lost := goQuery(conn, "SELECT 3")
+    waitFor(t, func() bool {
+        return len(conn.pending) == 2
+    })
+    s.unblock()
+
+    r := waitResult(t, first)
+    checkEcho(t, "BLOCK", r.rows, r.err)
+    if r := waitResult(t, drop); r.err == nil {
+        t.Error("expected error of command which lost connection")
+    }
+    if r := waitResult(t, lost); r.err == nil {
+        t.Error("expected error of command sent before failure")
+    }
+    rows, err := conn.Query("SELECT 4")
+    checkEcho(t, "SELECT 4", rows, err)
+    if n := s.connections(); n != 2 {
+        t.Errorf("expected 2 connections, got %d", n)
+    }
+}
+
+func TestPipelineKillTimeout(t *testing.T) {
+    release := make(chan struct{})
+    hang := make(chan struct{})
+    s := newTestServer(t, func(conn net.Conn, cmd []byte) [][]byte {
+        query := commandQuery(cmd)
+        switch {
+        case query == "BLOCK":
+            <-release
+        case strings.HasPrefix(query, "KILL QUERY"):
+            // server doesn't answer to kill
+            <-hang
+            return nil
+        }
+        return testResultSet(SERVER_STATUS_AUTOCOMMIT, []string{"q"}, []interface{}{query})
+    })
+    t.Cleanup(func() {
+        close(hang)
+    })
+    config := s.config()
+    config.ConnectTimeout = 100 * time.Millisecond
+    config.InitCommands = []string{"INIT"}
+    conn, err := Connect(config, context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+
+    ctx, cancel := context.WithCancel(context.Background())
+    killed := make(chan error)
+    go func() {
+        _, err := conn.QueryContext(ctx, "BLOCK")
+        killed <- err
+    }()
+    waitFor(t, func() bool {
+        return s.count(COM_QUERY) == 2
+    })
+    next := goQuery(conn, "SELECT 2")
+    cancel()
+    if err := <-killed; err != context.Canceled {
+        t.Errorf("expected cancelled query, got %v", err)
+    }
+    waitFor(t, func() bool {
+        return s.connections() == 2 && s.count(COM_QUERY) == 3
+    })
+    close(release)
+
+    // queue waits for kill until it times out
+    r := waitResult(t, next)
+    checkEcho(t, "SELECT 2", r.rows, r.err)
+    init := 0
+    for _, query := range s.queries() {
+        if query == "INIT" {
+            init++
+        }
+    }
+    if init != 1 {
+        t.Errorf("session of kill connection is set up, %q", s.queries())
+    }
+}
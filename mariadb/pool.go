@@ -0,0 +1,420 @@
+package mariadb
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "time"
+)
+
+var errPoolClosed = fmt.Errorf("pool is closed")
+var errPingTimeout = fmt.Errorf("pooled connection doesn't answer")
+var errReturnedInTx = fmt.Errorf("connection is returned to pool in transaction")
+
+// Time connection checked with PoolOptions.PingOnBorrow or reset
+// when it's returned must answer in
+const PoolPingTimeout = 5 * time.Second
+
+// Pool limits and timeouts
+type PoolOptions struct {
+    // Maximum number of open connections, 0 means unlimited
+    MaxOpen int
+    // Maximum number of idle connections, 0 means 2,
+    // negative value disables idle connections
+    MaxIdle int
+    // Connections older than this are closed, 0 means no limit
+    MaxLifetime time.Duration
+    // Connections idle longer than this are closed, 0 means no limit
+    MaxIdleTime time.Duration
+    // Ping idle connection before it is returned from Get, connection
+    // which doesn't answer in PoolPingTimeout is closed
+    PingOnBorrow bool
+}
+
+// Pool statistics
+type PoolStats struct {
+    MaxOpen int
+    Open int
+    InUse int
+    Idle int
+    // Number of Get calls which waited for connection
+    WaitCount int64
+    // Total time spent waiting for connection
+    WaitDuration time.Duration
+    MaxIdleClosed int64
+    MaxIdleTimeClosed int64
+    MaxLifetimeClosed int64
+}
+
+type pooledConnection struct {
+    conn *Connection
+    created time.Time
+    returned time.Time
+}
+
+// Pool of connections. Each Connection serves commands one by one,
+// pool lets concurrent callers use different connections.
+//
+//	pool := mariadb.NewPool(config, mariadb.PoolOptions{MaxOpen: 10})
+//	defer pool.Close()
+//	conn, err := pool.Get(ctx)
+//	if err != nil {
+//	    return err
+//	}
+//	defer pool.Put(conn)
+type Pool struct {
+    config Config
+    options PoolOptions
+
+    mu sync.Mutex
+    idle []pooledConnection
+    inUse map[*Connection]time.Time
+    open int
+    waiters []chan *Connection
+    closed bool
+    stats PoolStats
+    stop chan struct{}
+}
+
+func NewPool(config Config, options PoolOptions) *Pool {
+    if options.MaxIdle == 0 {
+        options.MaxIdle = 2
+    }
+    p := &Pool{
+        config: config,
+        options: options,
+        inUse: map[*Connection]time.Time{},
+        stop: make(chan struct{}),
+    }
+    if interval := p.cleanInterval(); interval > 0 {
+        go p.cleaner(interval)
+    }
+    return p
+}
+
+// Get idle connection or open new one. When MaxOpen connections are in use
+// Get waits until connection is returned or context is done.
+// Connection must be returned with Put.
+func (p *Pool) Get(ctx context.Context) (*Connection, error) {
+    p.mu.Lock()
+    for {
+        if p.closed {
+            p.mu.Unlock()
+            return nil, errPoolClosed
+        }
+
+        if n := len(p.idle); n > 0 {
+            pc := p.idle[n - 1]
+            p.idle = p.idle[:n - 1]
+            if p.expired(pc, time.Now()) {
+                p.open--
+                go pc.conn.Close()
+                continue
+            }
+            p.inUse[pc.conn] = pc.created
+            p.mu.Unlock()
+            if p.options.PingOnBorrow {
+                if err := p.ping(ctx, pc.conn); err != nil {
+                    if err == ctx.Err() {
+                        p.release(pc.conn)
+                        return nil, err
+                    }
+                    p.discard(pc.conn)
+                    p.mu.Lock()
+                    continue
+                }
+            }
+            return pc.conn, nil
+        }
+
+        if p.options.MaxOpen <= 0 || p.open < p.options.MaxOpen {
+            p.open++
+            p.mu.Unlock()
+            // ctx limits only dialing, connection outlives it
+            conn, err := connect(p.config, context.Background(), ctx)
+            p.mu.Lock()
+            if err != nil {
+                p.open--
+                p.wakeWaiter()
+                p.mu.Unlock()
+                return nil, err
+            }
+            p.inUse[conn] = time.Now()
+            p.mu.Unlock()
+            return conn, nil
+        }
+
+        // wait for returned connection or free slot
+        wait := make(chan *Connection, 1)
+        p.waiters = append(p.waiters, wait)
+        p.stats.WaitCount++
+        start := time.Now()
+        p.mu.Unlock()
+
+        select {
+        case conn := <-wait:
+            p.mu.Lock()
+            p.stats.WaitDuration += time.Since(start)
+            if conn != nil {
+                p.mu.Unlock()
+                return conn, nil
+            }
+        case <-ctx.Done():
+            p.mu.Lock()
+            p.stats.WaitDuration += time.Since(start)
+            p.removeWaiter(wait)
+            p.mu.Unlock()
+            // connection or free slot could be handed over
+            // before waiter was removed, pass it on
+            select {
+            case conn := <-wait:
+                if conn != nil {
+                    p.release(conn)
+                } else {
+                    p.mu.Lock()
+                    p.wakeWaiter()
+                    p.mu.Unlock()
+                }
+            default:
+            }
+            return nil, ctx.Err()
+        }
+    }
+}
+
+// Ping connection borrowed from pool. Ping isn't killed when ctx is done,
+// connection which doesn't answer in PoolPingTimeout is broken.
+func (p *Pool) ping(ctx context.Context, conn *Connection) error {
+    return p.check(ctx, conn, func() error {
+        _, err := conn.Ping(context.Background())
+        return err
+    })
+}
+
+// Run command on pooled connection, it isn't killed when ctx is done.
+// Connection which doesn't answer in PoolPingTimeout is broken.
+func (p *Pool) check(ctx context.Context, conn *Connection, command func() error) error {
+    done := make(chan error, 1)
+    go func() {
+        done <- command()
+    }()
+    timer := time.NewTimer(PoolPingTimeout)
+    defer timer.Stop()
+    select {
+    case err := <-done:
+        return err
+    case <-timer.C:
+        conn.fail(errPingTimeout)
+        return errPingTimeout
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// Return connection to pool. Session of connection is reset, so next user
+// doesn't see its variables, temporary tables and locks. Connection returned
+// in transaction, broken and expired connections are closed.
+func (p *Pool) Put(conn *Connection) {
+    p.mu.Lock()
+    _, ok := p.inUse[conn]
+    p.mu.Unlock()
+    if !ok {
+        return
+    }
+    if conn.ctx.Err() == nil {
+        var err error
+        if conn.status.Load() & SERVER_STATUS_IN_TRANS != 0 {
+            err = errReturnedInTx
+        } else {
+            err = p.check(context.Background(), conn, func() error {
+                return conn.Reset(context.Background())
+            })
+        }
+        if err != nil {
+            p.discard(conn)
+            return
+        }
+    }
+    p.release(conn)
+}
+
+// Return connection with clean session to pool or hand it over to waiter.
+// Broken and expired connections are closed.
+func (p *Pool) release(conn *Connection) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    created, ok := p.inUse[conn]
+    if !ok {
+        return
+    }
+    delete(p.inUse, conn)
+    pc := pooledConnection{conn: conn, created: created, returned: time.Now()}
+
+    if p.closed || conn.ctx.Err() != nil || p.expired(pc, pc.returned) {
+        p.open--
+        go conn.Close()
+        p.wakeWaiter()
+        return
+    }
+    if len(p.waiters) > 0 {
+        wait := p.waiters[0]
+        p.waiters = p.waiters[1:]
+        p.inUse[conn] = created
+        wait <- conn
+        return
+    }
+    if len(p.idle) >= p.options.MaxIdle {
+        p.open--
+        p.stats.MaxIdleClosed++
+        go conn.Close()
+        return
+    }
+    p.idle = append(p.idle, pc)
+}
+
+// Close connection instead of returning it to pool,
+// e.g. when its session state is unknown
+func (p *Pool) discard(conn *Connection) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if _, ok := p.inUse[conn]; !ok {
+        return
+    }
+    delete(p.inUse, conn)
+    p.open--
+    go conn.Close()
+    p.wakeWaiter()
+}
+
+// Let first waiter try to open connection. Caller must hold p.mu.
+func (p *Pool) wakeWaiter() {
+    if len(p.waiters) == 0 {
+        return
+    }
+    wait := p.waiters[0]
+    p.waiters = p.waiters[1:]
+    wait <- nil
+}
+
+func (p *Pool) removeWaiter(wait chan *Connection) {
+    for i, w := range p.waiters {
+        if w == wait {
+            p.waiters = append(p.waiters[:i], p.waiters[i + 1:]...)
+            return
+        }
+    }
+}
+
+// Check lifetime and idle time of connection, updates statistics
+func (p *Pool) expired(pc pooledConnection, now time.Time) bool {
+    if p.options.MaxLifetime > 0 && now.Sub(pc.created) > p.options.MaxLifetime {
+        p.stats.MaxLifetimeClosed++
+        return true
+    }
+    if p.options.MaxIdleTime > 0 && !pc.returned.IsZero() && now.Sub(pc.returned) > p.options.MaxIdleTime {
+        p.stats.MaxIdleTimeClosed++
+        return true
+    }
+    return false
+}
+
+func (p *Pool) cleanInterval() time.Duration {
+    interval := p.options.MaxLifetime
+    if p.options.MaxIdleTime > 0 && (interval == 0 || p.options.MaxIdleTime < interval) {
+        interval = p.options.MaxIdleTime
+    }
+    if interval > 0 && interval < time.Second {
+        interval = time.Second
+    }
+    return interval
+}
+
+// Close expired idle connections
+func (p *Pool) cleaner(interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+        case <-p.stop:
+            return
+        }
+        p.mu.Lock()
+        now := time.Now()
+        idle := p.idle[:0]
+        for _, pc := range p.idle {
+            if p.expired(pc, now) {
+                p.open--
+                go pc.conn.Close()
+            } else {
+                idle = append(idle, pc)
+            }
+        }
+        p.idle = idle
+        p.mu.Unlock()
+    }
+}
+
+func (p *Pool) Stats() PoolStats {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    stats := p.stats
+    stats.MaxOpen = p.options.MaxOpen
+    stats.Open = p.open
+    stats.InUse = len(p.inUse)
+    stats.Idle = len(p.idle)
+    return stats
+}
+
+// Close idle connections. Connections in use are closed when returned.
+func (p *Pool) Close() error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.closed {
+        return nil
+    }
+    p.closed = true
+    close(p.stop)
+    for _, pc := range p.idle {
+        p.open--
+        go pc.conn.Close()
+    }
+    p.idle = nil
+    for _, wait := range p.waiters {
+        wait <- nil
+    }
+    p.waiters = nil
+    return nil
+}
+
+// Run function with connection from pool
+func (p *Pool) Do(ctx context.Context, fn func(conn *Connection) error) error {
+    conn, err := p.Get(ctx)
+    if err != nil {
+        return err
+    }
+    defer p.Put(conn)
+    return fn(conn)
+}
+
+// Run query on connection from pool
+func (p *Pool) QueryContext(ctx context.Context, query string, args ...interface{}) (QueryResultRows, error) {
+    var rows QueryResultRows
+    err := p.Do(ctx, func(conn *Connection) error {
+        var err error
+        rows, err = conn.QueryContext(ctx, query, args...)
+        return err
+    })
+    return rows, err
+}
+
+// Run statement on connection from pool
+func (p *Pool) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+    var result Result
+    err := p.Do(ctx, func(conn *Connection) error {
+        var err error
+        result, err = conn.ExecContext(ctx, query, args...)
+        return err
+    })
+    return result, err
+}
@@ -0,0 +1,367 @@
+package mariadb
+
+import (
+    "context"
+    "net"
+    "testing"
+    "time"
+)
+
+func okServer(t *testing.T) *testServer {
+    return newTestServer(t, func(conn net.Conn, cmd []byte) [][]byte {
+        return [][]byte{testOK(0, 0, SERVER_STATUS_AUTOCOMMIT)}
+    })
+}
+
+func getConnection(t *testing.T, p *Pool) *Connection {
+    t.Helper()
+    conn, err := p.Get(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    return conn
+}
+
+func TestPoolReuse(t *testing.T) {
+    s := okServer(t)
+    p := NewPool(s.config(), PoolOptions{MaxOpen: 2})
+    defer p.Close()
+
+    conn := getConnection(t, p)
+    p.Put(conn)
+    again := getConnection(t, p)
+    if again != conn {
+        t.Error("idle connection isn't reused")
+    }
+    p.Put(again)
+    if stats := p.Stats(); stats.Open != 1 || stats.Idle != 1 || stats.InUse != 0 {
+        t.Errorf("unexpected stats %+v", stats)
+    }
+    if n := s.connections(); n != 1 {
+        t.Errorf("expected 1 connection, got %d", n)
+    }
+}
+
+func TestPoolWaitForConnection(t *testing.T) {
+    s := okServer(t)
+    p := NewPool(s.config(), PoolOptions{MaxOpen: 1})
+    defer p.Close()
+
+    conn := getConnection(t, p)
+    ctx, cancel := context.WithTimeout(context.Background(), 20 * time.Millisecond)
+    defer cancel()
+    if _, err := p.Get(ctx); err != context.DeadlineExceeded {
+        t.Fatalf("expected deadline error, got %v", err)
+    }
+
+    got := make(chan *Connection)
+    go func() {
+        conn, _ := p.Get(context.Background())
+        got <- conn
+    }()
+    waitFor(t, func() bool {
+        return p.Stats().WaitCount == 2
+    })
+    p.Put(conn)
+    if <-got != conn {
+        t.Error("returned connection isn't handed to waiter")
+    }
+    if stats := p.Stats(); stats.Open != 1 || stats.InUse != 1 {
+        t.Errorf("unexpected stats %+v", stats)
+    }
+}
+
+func TestPoolMaxIdle(t *testing.T) {
+    s := okServer(t)
+    p := NewPool(s.config(), PoolOptions{MaxIdle: 1})
+    defer p.Close()
+
+    conns := []*Connection{getConnection(t, p), getConnection(t, p), getConnection(t, p)}
+    for _, conn := range conns {
+        p.Put(conn)
+    }
+    if stats := p.Stats(); stats.Open != 1 || stats.Idle != 1 || stats.MaxIdleClosed != 2 {
+        t.Errorf("unexpected stats %+v", stats)
+    }
+}
+
+func TestPoolMaxLifetime(t *testing.T) {
+    s := okServer(t)
+    p := NewPool(s.config(), PoolOptions{MaxLifetime: 10 * time.Millisecond})
+    defer p.Close()
+
+    conn := getConnection(t, p)
+    time.Sleep(20 * time.Millisecond)
+    p.Put(conn)
+    if stats := p.Stats(); stats.Open != 0 || stats.Idle != 0 || stats.MaxLifetimeClosed != 1 {
+        t.Errorf("unexpected stats %+v", stats)
+    }
+    if fresh := getConnection(t, p); fresh == conn {
+        t.Error("expired connection is reused")
+    }
+}
+
+func TestPoolBrokenConnectionIsClosed(t *testing.T) {
+    s := okServer(t)
+    p := NewPool(s.config(), PoolOptions{})
+    defer p.Close()
+
+    conn := getConnection(t, p)
+    s.dropConnections()
+    if _, err := conn.Ping(context.Background()); err == nil {
+        t.Fatal("expected error of dropped connection")
+    }
+    p.Put(conn)
+    if stats := p.Stats(); stats.Open != 0 || stats.Idle != 0 {
+        t.Errorf("unexpected stats %+v", stats)
+    }
+    if fresh := getConnection(t, p); fresh == conn {
+        t.Error("broken connection is reused")
+    }
+}
+
+func TestPoolPingOnBorrow(t *testing.T) {
+    s := okServer(t)
+    p := NewPool(s.config(), PoolOptions{PingOnBorrow: true})
+    defer p.Close()
+
+    conn := getConnection(t, p)
+    p.Put(conn)
+    s.dropConnections()
+
+    fresh := getConnection(t, p)
+    if fresh == conn {
+        t.Error("dropped idle connection is returned")
+    }
+    p.Put(fresh)
+    if stats := p.Stats(); stats.Open != 1 || stats.Idle != 1 {
+        t.Errorf("unexpected stats %+v", stats)
+    }
+}
+
+func TestPoolClose(t *testing.T) {
+    s := okServer(t)
+    p := NewPool(s.config(), PoolOptions{MaxOpen: 1})
+
+    conn := getConnection(t, p)
+    waiter := make(chan error)
+    go func() {
+        _, err := p.Get(context.Background())
+        waiter <- err
+    }()
+    waitFor(t, func() bool {
+        return p.Stats().WaitCount == 1
+    })
+    p.Close()
+    if err := <-waiter; err != errPoolClosed {
+        t.Errorf("expected closed pool error of waiter, got %v", err)
+    }
+    if _, err := p.Get(context.Background()); err != errPoolClosed {
+        t.Errorf("expected closed pool error, got %v", err)
+    }
+    p.Put(conn)
+    if stats := p.Stats(); stats.Open != 0 {
+        t.Errorf("connection in use isn't closed when returned: %+v", stats)
+    }
+}
+
+func TestPoolDo(t *testing.T) {
+    s := okServer(t)
+    p := NewPool(s.config(), PoolOptions{})
+    defer p.Close()
+
+    err := p.Do(context.Background(), func(conn *Connection) error {
+        if stats := p.Stats(); stats.InUse != 1 {
+            t.Errorf("unexpected stats %+v", stats)
+        }
+        return nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if stats := p.Stats(); stats.InUse != 0 || stats.Idle != 1 {
+        t.Errorf("connection isn't returned: %+v", stats)
+    }
+}
+
+// Free slot handed to waiter whose context is done must be passed on
+func TestPoolWakeUpAfterCancelledWaiter(t *testing.T) {
+    s := okServer(t)
+    p := NewPool(s.config(), PoolOptions{MaxOpen: 1})
+    defer p.Close()
+
+    waits := int64(0)
+    for i := 0; i < 100; i++ {
+        conn := getConnection(t, p)
+        // returned broken connection frees slot
+        s.dropConnections()
+        conn.Ping(context.Background())
+
+        ctxA, cancelA := context.WithCancel(context.Background())
+        connA := make(chan *Connection, 1)
+        go func() {
+            conn, _ := p.Get(ctxA)
+            connA <- conn
+        }()
+        waits++
+        waitFor(t, func() bool {
+            return p.Stats().WaitCount == waits
+        })
+        ctxB, cancelB := context.WithTimeout(context.Background(), time.Second)
+        connB := make(chan *Connection, 1)
+        go func() {
+            conn, _ := p.Get(ctxB)
+            connB <- conn
+        }()
+        waits++
+        waitFor(t, func() bool {
+            return p.Stats().WaitCount == waits
+        })
+
+        // first waiter gives up while slot is freed
+        cancelA()
+        p.Put(conn)
+        if conn := <-connA; conn != nil {
+            p.Put(conn)
+        }
+        conn = <-connB
+        cancelB()
+        if conn == nil {
+            t.Fatal("second waiter didn't get free slot")
+        }
+        p.Put(conn)
+    }
+}
+
+func TestPoolDialRespectsContext(t *testing.T) {
+    // accepts connections but never sends handshake
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer ln.Close()
+    go func() {
+        for {
+            conn, err := ln.Accept()
+            if err != nil {
+                return
+            }
+            defer conn.Close()
+        }
+    }()
+
+    p := NewPool(Config{Uri: ln.Addr().String(), Username: "test"}, PoolOptions{MaxOpen: 1})
+    defer p.Close()
+    ctx, cancel := context.WithTimeout(context.Background(), 50 * time.Millisecond)
+    defer cancel()
+    start := time.Now()
+    _, err = p.Get(ctx)
+    if err != context.DeadlineExceeded {
+        t.Fatalf("expected deadline error, got %v", err)
+    }
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Errorf("Get returned after %s", elapsed)
+    }
+    if stats := p.Stats(); stats.Open != 0 {
+        t.Errorf("slot isn't returned: %+v", stats)
+    }
+}
+
+func TestPoolConnectionOutlivesContext(t *testing.T) {
+    s := okServer(t)
+    p := NewPool(s.config(), PoolOptions{})
+    defer p.Close()
+
+    ctx, cancel := context.WithCancel(context.Background())
+    conn, err := p.Get(ctx)
+    if err != nil {
+        t.Fatal(err)
+    }
+    cancel()
+    if _, err := conn.Exec("SELECT 1"); err != nil {
+        t.Fatalf("connection is closed with context of Get: %v", err)
+    }
+    p.Put(conn)
+}
+
+func TestPoolPingOnBorrowIsNotKilled(t *testing.T) {
+    s := okServer(t)
+    p := NewPool(s.config(), PoolOptions{PingOnBorrow: true})
+    defer p.Close()
+
+    p.Put(getConnection(t, p))
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    conn, err := p.Get(ctx)
+    if err != nil {
+        t.Fatal(err)
+    }
+    p.Put(conn)
+    if n := s.count(COM_PING); n != 1 {
+        t.Errorf("expected 1 ping, got %d", n)
+    }
+    cancel()
+    // KILL QUERY would be sent from side connection
+    if n := s.connections(); n != 1 {
+        t.Errorf("expected 1 connection, got %d", n)
+    }
+}
+
+func TestPoolPutResetsSession(t *testing.T) {
+    s := okServer(t)
+    config := s.config()
+    config.InitCommands = []string{"INIT"}
+    p := NewPool(config, PoolOptions{MaxOpen: 1})
+    defer p.Close()
+
+    conn := getConnection(t, p)
+    if _, err := conn.Exec("SET @a = 1"); err != nil {
+        t.Fatal(err)
+    }
+    p.Put(conn)
+    if n := s.count(COM_RESET_CONN); n != 1 {
+        t.Errorf("expected reset of returned connection, got %d resets", n)
+    }
+    if stats := p.Stats(); stats.Open != 1 || stats.Idle != 1 {
+        t.Errorf("unexpected stats %+v", stats)
+    }
+    // init commands are run again after reset
+    if queries := s.queries(); len(queries) != 3 || queries[2] != "INIT" {
+        t.Errorf("unexpected queries %q", queries)
+    }
+    if again := getConnection(t, p); again != conn {
+        t.Error("reset connection isn't reused")
+    }
+}
+
+func TestPoolPutInTransaction(t *testing.T) {
+    s := newTestServer(t, func(conn net.Conn, cmd []byte) [][]byte {
+        if commandQuery(cmd) == "BEGIN" {
+            return [][]byte{testOK(0, 0, SERVER_STATUS_AUTOCOMMIT | SERVER_STATUS_IN_TRANS)}
+        }
+        return [][]byte{testOK(0, 0, SERVER_STATUS_AUTOCOMMIT)}
+    })
+    p := NewPool(s.config(), PoolOptions{MaxOpen: 1})
+    defer p.Close()
+
+    conn := getConnection(t, p)
+    if _, err := conn.Exec("BEGIN"); err != nil {
+        t.Fatal(err)
+    }
+    p.Put(conn)
+    if stats := p.Stats(); stats.Open != 0 || stats.Idle != 0 {
+        t.Errorf("connection in transaction is kept, stats %+v", stats)
+    }
+    if n := s.count(COM_RESET_CONN); n != 0 {
+        t.Errorf("connection in transaction is reset")
+    }
+
+    again := getConnection(t, p)
+    if again == conn {
+        t.Error("connection in transaction is reused")
+    }
+    p.Put(again)
+    if n := s.connections(); n != 2 {
+        t.Errorf("expected 2 connections, got %d", n)
+    }
+}
@@ -1,17 +1,49 @@
 package mariadb
 
+import (
+    "context"
+)
+
 // used for transporting packet between channels
 type queuePacket struct {
     c chan queuePacket
     packet *Packet
     error error
+    // command is not sent when context is done while it waits in queue,
+    // running command is killed
+    ctx context.Context
+    // command refers to prepared statement of given connection generation
+    statement bool
+    generation uint64
+    // request of exclusive session, commands are read from
+    // this channel until it is closed
+    session chan queuePacket
+    // command with context is sent without waiting for previous
+    // responses, context doesn't kill it
+    pipeline bool
+}
+
+// Number of commands sent without waiting for their responses
+const PipelineDepth = 32
+
+// Command must be sent when responses of previous commands are read
+// and next command waits for its response
+func (q queuePacket) exclusive() bool {
+    if q.ctx != nil && q.ctx.Done() != nil && !q.pipeline {
+        // running command is killed when context is done
+        return true
+    }
+    switch q.packet.peekAt(4) {
+    case COM_QUIT, COM_BINLOG_DUMP:
+        return true
+    }
+    return false
 }
 
 func createQueuePacket(packet *Packet) queuePacket {
     return queuePacket{
-         make(chan queuePacket, 10),
-         packet,
-         nil,
+         c: make(chan queuePacket, 10),
+         packet: packet,
     }
 }
 
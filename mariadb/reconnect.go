@@ -0,0 +1,96 @@
+package mariadb
+
+import (
+    "fmt"
+)
+
+// Returned for command of statement prepared before reconnect
+var errStatementLost = fmt.Errorf("prepared statement is lost on reconnect")
+
+// Returned for first command after reconnect when connection was lost
+// inside of transaction. Transaction is rolled back by server, so it
+// must be started again.
+type TxLostError struct {
+    // error which broke connection
+    Err error
+}
+
+func (e *TxLostError) Error() string {
+    return fmt.Sprintf("transaction lost on reconnect: %v", e.Err)
+}
+
+func (e *TxLostError) Unwrap() error {
+    return e.Err
+}
+
+// Current database, changes made with USE are tracked
+func (c *Connection) Database() string {
+    database, _ := c.database.Load().(string)
+    return database
+}
+
+// Reconnect before next command when connection is broken. Command which
+// was running when connection was lost is not repeated, its caller gets
+// I/O error. Called only by goroutine serving the queue.
+func (c *Connection) restore() error {
+    broken := c.brokenError()
+    inTx := c.status.Load() & SERVER_STATUS_IN_TRANS != 0
+    err := c.reconnect()
+    if err != nil {
+        return fmt.Errorf("reconnect failed: %w", err)
+    }
+    if inTx {
+        return &TxLostError{Err: broken}
+    }
+    return nil
+}
+
+// Open new socket to host matching Config.Role, do handshake with last used
+// database and collation, then restore session variables. Prepared statements are prepared
+// again on next execution.
+func (c *Connection) reconnect() error {
+    err := c.open(c.ctx)
+    if err != nil {
+        return err
+    }
+    c.setBroken(nil)
+    c.generation.Add(1)
+
+    err = c.setupSession(c.execDirect)
+    if err != nil && c.brokenError() == nil {
+        // session is incomplete, try again on next command
+        c.setBroken(err)
+        c.socket.Close()
+    }
+    return err
+}
+
+// I/O error which broke connection with AutoReconnect
+func (c *Connection) brokenError() error {
+    c.brokenMu.Lock()
+    defer c.brokenMu.Unlock()
+    return c.broken
+}
+
+func (c *Connection) setBroken(err error) {
+    c.brokenMu.Lock()
+    defer c.brokenMu.Unlock()
+    c.broken = err
+}
+
+// Run query bypassing the queue. Called only by goroutine serving the queue
+// when there are no responses to read.
+func (c *Connection) execDirect(query string) error {
+    q := createQueuePacket(createQueryPacket(query))
+    err := c.send(q.packet)
+    if err != nil {
+        return err
+    }
+    go c.recvResponse(&q)
+    for response := range q.c {
+        if response.error != nil {
+            err = response.error
+        }
+    }
+    return err
+}
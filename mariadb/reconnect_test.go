@@ -0,0 +1,173 @@
+package mariadb
+
+import (
+    "context"
+    "encoding/binary"
+    "errors"
+    "net"
+    "reflect"
+    "strings"
+    "sync/atomic"
+    "testing"
+)
+
+// Server closing connection on DROP query. Statements get new id
+// on every prepare and have single parameter.
+func reconnectServer(t *testing.T) *testServer {
+    var statementId uint32
+    return newTestServer(t, func(conn net.Conn, cmd []byte) [][]byte {
+        switch cmd[0] {
+        case COM_STMT_PREPARE:
+            // See https://mariadb.com/kb/en/com_stmt_prepare/#COM_STMT_PREPARE_OK
+            ok := binary.LittleEndian.AppendUint32([]byte{0}, atomic.AddUint32(&statementId, 1))
+            ok = append(ok, 0, 0, 1, 0, 0, 0, 0)
+            return [][]byte{ok, testColumn("?")}
+        case COM_STMT_SEND_LONG_DATA, COM_STMT_CLOSE:
+            return nil
+        case COM_QUERY:
+            switch commandQuery(cmd) {
+            case "DROP":
+                conn.Close()
+                return nil
+            case "BEGIN":
+                return [][]byte{testOK(0, 0, SERVER_STATUS_AUTOCOMMIT | SERVER_STATUS_IN_TRANS)}
+            }
+        }
+        return [][]byte{testOK(1, 0, SERVER_STATUS_AUTOCOMMIT)}
+    })
+}
+
+func connectAutoReconnect(t *testing.T, s *testServer) *Connection {
+    config := s.config()
+    config.AutoReconnect = true
+    config.Params = map[string]string{"sql_mode": "'ANSI'"}
+    config.InitCommands = []string{"SET @a = 1"}
+    conn, err := Connect(config, context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(conn.Close)
+    return conn
+}
+
+func TestReconnectRestoresSession(t *testing.T) {
+    s := reconnectServer(t)
+    conn := connectAutoReconnect(t, s)
+
+    if _, err := conn.Exec("DROP"); err == nil {
+        t.Fatal("expected error of command which lost connection")
+    }
+    if _, err := conn.Exec("SELECT 1"); err != nil {
+        t.Fatalf("command after reconnect failed: %v", err)
+    }
+    if n := s.connections(); n != 2 {
+        t.Errorf("expected 2 connections, got %d", n)
+    }
+    expected := []string{
+        "SET sql_mode = 'ANSI'", "SET @a = 1", "DROP",
+        "SET sql_mode = 'ANSI'", "SET @a = 1", "SELECT 1",
+    }
+    if queries := s.queries(); !reflect.DeepEqual(queries, expected) {
+        t.Errorf("queries %q, expected %q", queries, expected)
+    }
+}
+
+func TestReconnectWithoutAutoReconnect(t *testing.T) {
+    s := reconnectServer(t)
+    conn, err := Connect(s.config(), context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+
+    if _, err := conn.Exec("DROP"); err == nil {
+        t.Fatal("expected error of command which lost connection")
+    }
+    _, err = conn.Exec("SELECT 1")
+    if err == nil || !strings.Contains(err.Error(), "connection is broken") {
+        t.Errorf("expected broken connection error, got %v", err)
+    }
+    if n := s.connections(); n != 1 {
+        t.Errorf("expected 1 connection, got %d", n)
+    }
+}
+
+func TestReconnectPreparesStatementAgain(t *testing.T) {
+    s := reconnectServer(t)
+    conn := connectAutoReconnect(t, s)
+
+    stmt, err := conn.Prepare("UPDATE t SET a = ?")
+    if err != nil {
+        t.Fatal(err)
+    }
+    conn.Exec("DROP")
+
+    // statement id of lost connection must not be executed
+    result, err := stmt.Exec(1)
+    if err != nil {
+        t.Fatalf("statement after reconnect failed: %v", err)
+    }
+    if result.AffectedRows != 1 {
+        t.Errorf("unexpected result %+v", result)
+    }
+    if n := s.count(COM_STMT_PREPARE); n != 2 {
+        t.Errorf("expected 2 prepares, got %d", n)
+    }
+    if n := s.count(COM_STMT_EXECUTE); n != 1 {
+        t.Errorf("expected 1 execution, got %d", n)
+    }
+    if stmt.id != 2 {
+        t.Errorf("statement id %d, expected id of second prepare", stmt.id)
+    }
+}
+
+func TestReconnectStreamedStatementIsNotRepeated(t *testing.T) {
+    s := reconnectServer(t)
+    conn := connectAutoReconnect(t, s)
+
+    stmt, err := conn.Prepare("INSERT INTO t VALUES (?)")
+    if err != nil {
+        t.Fatal(err)
+    }
+    conn.Exec("DROP")
+
+    // reader is consumed by first attempt, so statement can't be executed again
+    _, err = stmt.Exec(strings.NewReader("data"))
+    if !errors.Is(err, errStatementLost) {
+        t.Fatalf("expected lost statement error, got %v", err)
+    }
+    if n := s.count(COM_STMT_EXECUTE); n != 0 {
+        t.Errorf("expected no execution, got %d", n)
+    }
+
+    if _, err := stmt.Exec(strings.NewReader("data")); err != nil {
+        t.Fatalf("statement isn't prepared again: %v", err)
+    }
+    if n := s.count(COM_STMT_SEND_LONG_DATA); n != 1 {
+        t.Errorf("expected long data on new connection only, got %d", n)
+    }
+}
+
+func TestReconnectInsideOfTransaction(t *testing.T) {
+    s := reconnectServer(t)
+    conn := connectAutoReconnect(t, s)
+
+    if _, err := conn.Exec("BEGIN"); err != nil {
+        t.Fatal(err)
+    }
+    conn.Exec("DROP")
+
+    _, err := conn.Exec("UPDATE t SET a = 1")
+    var lost *TxLostError
+    if !errors.As(err, &lost) {
+        t.Fatalf("expected TxLostError, got %v", err)
+    }
+    if _, err := conn.Exec("UPDATE t SET a = 1"); err != nil {
+        t.Errorf("command after TxLostError failed: %v", err)
+    }
+    // command which got TxLostError isn't sent
+    queries := s.queries()
+    if n := len(queries); queries[n - 1] != "UPDATE t SET a = 1" || queries[n - 2] == "UPDATE t SET a = 1" {
+        t.Errorf("unexpected queries %q", queries)
+    }
+}
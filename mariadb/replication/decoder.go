@@ -0,0 +1,226 @@
+package replication
+
+import (
+    "encoding/binary"
+    "fmt"
+    "hash/crc32"
+    "strings"
+)
+
+var ErrChecksum = fmt.Errorf("binlog event checksum mismatch")
+
+// Decodes binlog events. Keeps format description and table maps
+// which are required to decode following events, so every event
+// of stream must be passed to the same decoder in order.
+type Decoder struct {
+    format *FormatDescriptionEvent
+    tables map[uint64]*TableMapEvent
+}
+
+func NewDecoder() *Decoder {
+    return &Decoder{
+        tables: map[uint64]*TableMapEvent{},
+    }
+}
+
+// Decode event including header. Checksum is verified
+// when format description event announces CRC32.
+func (d *Decoder) Decode(data []byte) (Event, error) {
+    if len(data) < EventHeaderSize {
+        return nil, errShortEvent
+    }
+    r := &reader{data: data}
+    header := EventHeader{
+        Timestamp: r.readUInt32(),
+        Type: r.readUInt8(),
+        ServerId: r.readUInt32(),
+        EventSize: r.readUInt32(),
+        LogPos: r.readUInt32(),
+        Flags: r.readUInt16(),
+    }
+    if int(header.EventSize) != len(data) {
+        return nil, fmt.Errorf("binlog event size %d doesn't match header size %d", len(data), header.EventSize)
+    }
+
+    if header.Type == FORMAT_DESCRIPTION_EVENT {
+        event, err := decodeFormatDescription(header, data)
+        if err != nil {
+            return nil, err
+        }
+        d.format = event
+        return event, nil
+    }
+
+    if d.format == nil {
+        // Rotate event sent before format description by replication
+        // stream has checksum if binlog has it, but algorithm is unknown yet
+        if header.Type == ROTATE_EVENT && len(data) > EventHeaderSize + 12 && verifyChecksum(data) {
+            data = data[:len(data)-4]
+        }
+    } else if d.format.ChecksumAlgorithm == BINLOG_CHECKSUM_ALG_CRC32 {
+        if !verifyChecksum(data) {
+            return nil, ErrChecksum
+        }
+        data = data[:len(data)-4]
+    }
+
+    r = &reader{data: data, pos: EventHeaderSize}
+    var event Event
+    switch header.Type {
+    case ROTATE_EVENT:
+        event = &RotateEvent{
+            EventHeader: header,
+            Position: r.readUInt64(),
+            NextFile: string(r.rest()),
+        }
+    case QUERY_EVENT:
+        event = decodeQuery(header, r)
+    case TABLE_MAP_EVENT:
+        table := d.decodeTableMap(header, r)
+        if r.err == nil {
+            d.tables[table.TableId] = table
+        }
+        event = table
+    case WRITE_ROWS_EVENT_V1, UPDATE_ROWS_EVENT_V1, DELETE_ROWS_EVENT_V1,
+        WRITE_ROWS_EVENT, UPDATE_ROWS_EVENT, DELETE_ROWS_EVENT:
+        rows, err := d.decodeRows(header, r)
+        if err != nil {
+            return nil, err
+        }
+        event = rows
+    case XID_EVENT:
+        event = &XIDEvent{
+            EventHeader: header,
+            XID: r.readUInt64(),
+        }
+    case GTID_EVENT:
+        gtid := &GTIDEvent{
+            EventHeader: header,
+            Sequence: r.readUInt64(),
+            Domain: r.readUInt32(),
+            GTIDFlags: r.readUInt8(),
+        }
+        if gtid.GTIDFlags & FL_GROUP_COMMIT_ID != 0 {
+            gtid.CommitId = r.readUInt64()
+        }
+        event = gtid
+    case HEARTBEAT_LOG_EVENT:
+        event = &HeartbeatEvent{
+            EventHeader: header,
+            LogFile: string(r.rest()),
+        }
+    default:
+        event = &UnknownEvent{
+            EventHeader: header,
+            Data: r.rest(),
+        }
+    }
+
+    if r.err != nil {
+        return nil, fmt.Errorf("binlog event type %d: %w", header.Type, r.err)
+    }
+    return event, nil
+}
+
+// Last 4 bytes of event are CRC32 of the rest of event
+func verifyChecksum(data []byte) bool {
+    n := len(data) - 4
+    return crc32.ChecksumIEEE(data[:n]) == binary.LittleEndian.Uint32(data[n:])
+}
+
+// Checksums were introduced in MySQL 5.6.1 and MariaDB 5.3
+func checksumSupported(serverVersion string) bool {
+    min := []int{5, 6, 1}
+    if strings.Contains(serverVersion, "MariaDB") {
+        min = []int{5, 3, 0}
+    }
+    parts := strings.SplitN(serverVersion, ".", 3)
+    for i := 0; i < 3; i++ {
+        v := 0
+        if i < len(parts) {
+            v = leadingNumber(parts[i])
+        }
+        if v != min[i] {
+            return v > min[i]
+        }
+    }
+    return true
+}
+
+func leadingNumber(s string) int {
+    v := 0
+    for _, c := range s {
+        if c < '0' || c > '9' {
+            break
+        }
+        v = v * 10 + int(c - '0')
+    }
+    return v
+}
+
+// See https://mariadb.com/kb/en/format_description_event/
+func decodeFormatDescription(header EventHeader, data []byte) (*FormatDescriptionEvent, error) {
+    r := &reader{data: data, pos: EventHeaderSize}
+    event := &FormatDescriptionEvent{
+        EventHeader: header,
+        BinlogVersion: r.readUInt16(),
+        ServerVersion: strings.TrimRight(string(r.bytes(50)), "\x00"),
+        CreateTimestamp: r.readUInt32(),
+        HeaderLength: r.readUInt8(),
+        ChecksumAlgorithm: BINLOG_CHECKSUM_ALG_UNDEF,
+    }
+    if r.err != nil {
+        return nil, fmt.Errorf("format description event: %w", r.err)
+    }
+
+    lengths := r.rest()
+    if checksumSupported(event.ServerVersion) && len(lengths) >= 5 {
+        event.ChecksumAlgorithm = lengths[len(lengths)-5]
+        lengths = lengths[:len(lengths)-5]
+        if event.ChecksumAlgorithm == BINLOG_CHECKSUM_ALG_CRC32 {
+            // checksum is calculated without in-use flag, which
+            // server clears when binlog file is closed
+            cleared := make([]byte, len(data))
+            copy(cleared, data)
+            cleared[17] &^= LOG_EVENT_BINLOG_IN_USE_F
+            if !verifyChecksum(cleared) {
+                return nil, ErrChecksum
+            }
+        }
+    }
+    event.PostHeaderLengths = lengths
+    return event, nil
+}
+
+// Length of post header of event type
+func (d *Decoder) postHeaderLength(eventType uint8, fallback int) int {
+    if d.format == nil || int(eventType) > len(d.format.PostHeaderLengths) || eventType == 0 {
+        return fallback
+    }
+    return int(d.format.PostHeaderLengths[eventType-1])
+}
+
+// Table id is 6 bytes long, except of very old servers
+func (d *Decoder) readTableId(r *reader, eventType uint8, defaultPostHeader int) uint64 {
+    if d.postHeaderLength(eventType, defaultPostHeader) == 6 {
+        return uint64(r.readUInt32())
+    }
+    return r.readUInt48()
+}
+
+// See https://mariadb.com/kb/en/query_event/
+func decodeQuery(header EventHeader, r *reader) *QueryEvent {
+    event := &QueryEvent{
+        EventHeader: header,
+        ThreadId: r.readUInt32(),
+        ExecutionTime: r.readUInt32(),
+    }
+    schemaLength := int(r.readUInt8())
+    event.ErrorCode = r.readUInt16()
+    statusLength := int(r.readUInt16())
+    r.skip(statusLength)
+    event.Schema = string(r.bytes(schemaLength))
+    r.skip(1)
+    event.Query = string(r.rest())
+    return event
+}
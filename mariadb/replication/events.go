@@ -0,0 +1,184 @@
+package replication
+
+import (
+    "fmt"
+)
+
+// See https://mariadb.com/kb/en/2-binlog-event-header/#event-type
+const UNKNOWN_EVENT = 0
+const START_EVENT_V3 = 1
+const QUERY_EVENT = 2
+const STOP_EVENT = 3
+const ROTATE_EVENT = 4
+const INTVAR_EVENT = 5
+const RAND_EVENT = 13
+const USER_VAR_EVENT = 14
+const FORMAT_DESCRIPTION_EVENT = 15
+const XID_EVENT = 16
+const TABLE_MAP_EVENT = 19
+const WRITE_ROWS_EVENT_V1 = 23
+const UPDATE_ROWS_EVENT_V1 = 24
+const DELETE_ROWS_EVENT_V1 = 25
+const INCIDENT_EVENT = 26
+const HEARTBEAT_LOG_EVENT = 27
+const WRITE_ROWS_EVENT = 30
+const UPDATE_ROWS_EVENT = 31
+const DELETE_ROWS_EVENT = 32
+const ANNOTATE_ROWS_EVENT = 160
+const BINLOG_CHECKPOINT_EVENT = 161
+const GTID_EVENT = 162
+const GTID_LIST_EVENT = 163
+const START_ENCRYPTION_EVENT = 164
+
+// See https://mariadb.com/kb/en/2-binlog-event-header/#event-flag
+const LOG_EVENT_BINLOG_IN_USE_F = 0x1
+const LOG_EVENT_ARTIFICIAL_F = 0x20
+
+// See https://mariadb.com/kb/en/format_description_event/
+const BINLOG_CHECKSUM_ALG_OFF = 0
+const BINLOG_CHECKSUM_ALG_CRC32 = 1
+const BINLOG_CHECKSUM_ALG_UNDEF = 255
+
+// See https://mariadb.com/kb/en/gtid_event/
+const FL_STANDALONE = 1
+const FL_GROUP_COMMIT_ID = 2
+
+// Size of event header in binlog version 4
+const EventHeaderSize = 19
+
+// Decoded binlog event
+type Event interface {
+    Header() *EventHeader
+}
+
+// See https://mariadb.com/kb/en/2-binlog-event-header/
+type EventHeader struct {
+    Timestamp uint32
+    Type uint8
+    ServerId uint32
+    EventSize uint32
+    // Position of next event in binlog file
+    LogPos uint32
+    Flags uint16
+}
+
+func (h *EventHeader) Header() *EventHeader {
+    return h
+}
+
+// See https://mariadb.com/kb/en/format_description_event/
+type FormatDescriptionEvent struct {
+    EventHeader
+    BinlogVersion uint16
+    ServerVersion string
+    CreateTimestamp uint32
+    HeaderLength uint8
+    PostHeaderLengths []byte
+    ChecksumAlgorithm uint8
+}
+
+// See https://mariadb.com/kb/en/rotate_event/
+type RotateEvent struct {
+    EventHeader
+    Position uint64
+    NextFile string
+}
+
+// See https://mariadb.com/kb/en/query_event/
+type QueryEvent struct {
+    EventHeader
+    ThreadId uint32
+    ExecutionTime uint32
+    ErrorCode uint16
+    Schema string
+    Query string
+}
+
+// See https://mariadb.com/kb/en/table_map_event/
+type TableMapEvent struct {
+    EventHeader
+    TableId uint64
+    Flags uint16
+    Schema string
+    Table string
+    ColumnTypes []byte
+    ColumnMeta []uint16
+    NullBitmap []byte
+    // Present only when server has binlog_row_metadata=FULL
+    ColumnNames []string
+    // Unsigned flag of each column. Set only when server sends
+    // signedness metadata, otherwise all numbers are decoded as signed.
+    Unsigned []bool
+}
+
+func (e *TableMapEvent) Nullable(column int) bool {
+    return e.NullBitmap[column / 8] & (1 << (column % 8)) != 0
+}
+
+// Before and after images of changed row.
+// Before is nil for inserted rows, After is nil for deleted rows.
+// Columns not included into image (binlog_row_image=MINIMAL) are nil.
+type RowChange struct {
+    Before []interface{}
+    After []interface{}
+}
+
+// WRITE_ROWS, UPDATE_ROWS and DELETE_ROWS events, version 1 and 2.
+// See https://mariadb.com/kb/en/rows_event_v1v2/
+type RowsEvent struct {
+    EventHeader
+    TableId uint64
+    Flags uint16
+    Table *TableMapEvent
+    ColumnCount int
+    // Columns included into before and after images
+    BeforeColumns []bool
+    AfterColumns []bool
+    Rows []RowChange
+}
+
+func (e *RowsEvent) IsWrite() bool {
+    return e.Type == WRITE_ROWS_EVENT_V1 || e.Type == WRITE_ROWS_EVENT
+}
+
+func (e *RowsEvent) IsUpdate() bool {
+    return e.Type == UPDATE_ROWS_EVENT_V1 || e.Type == UPDATE_ROWS_EVENT
+}
+
+func (e *RowsEvent) IsDelete() bool {
+    return e.Type == DELETE_ROWS_EVENT_V1 || e.Type == DELETE_ROWS_EVENT
+}
+
+// See https://mariadb.com/kb/en/xid_event/
+type XIDEvent struct {
+    EventHeader
+    XID uint64
+}
+
+// MariaDB GTID event, starts event group.
+// See https://mariadb.com/kb/en/gtid_event/
+type GTIDEvent struct {
+    EventHeader
+    Sequence uint64
+    Domain uint32
+    GTIDFlags uint8
+    CommitId uint64
+}
+
+// GTID in MariaDB format domain-server-sequence
+func (e *GTIDEvent) GTID() string {
+    return fmt.Sprintf("%d-%d-%d", e.Domain, e.ServerId, e.Sequence)
+}
+
+// Sent by server when there are no events during heartbeat period.
+// See https://mariadb.com/kb/en/heartbeat_log_event/
+type HeartbeatEvent struct {
+    EventHeader
+    LogFile string
+}
+
+// Event types which are not decoded
+type UnknownEvent struct {
+    EventHeader
+    Data []byte
+}
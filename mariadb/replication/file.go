@@ -0,0 +1,119 @@
+package replication
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "os"
+)
+
+// Every binlog file starts with these bytes
+var BinlogMagic = []byte{0xfe, 'b', 'i', 'n'}
+
+// Default limit of event size, matches max value of max_allowed_packet
+const DefaultMaxEventSize = 1 << 30
+
+// Reads events from binlog file, like mysqlbinlog does.
+//
+//	file, err := replication.OpenFile("mysql-bin.000001")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer file.Close()
+//	for {
+//	    event, err := file.Next()
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    if err != nil {
+//	        log.Fatal(err)
+//	    }
+//	    if rows, ok := event.(*replication.RowsEvent); ok {
+//	        fmt.Println(strings.Join(rows.SQL(), "\n"))
+//	    }
+//	}
+type FileReader struct {
+    r *bufio.Reader
+    closer io.Closer
+    decoder *Decoder
+    position uint32
+    // Events larger than this are rejected as corrupted, DefaultMaxEventSize by default
+    MaxEventSize uint32
+}
+
+// Open binlog file from disk
+func OpenFile(path string) (*FileReader, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    reader, err := NewFileReader(f)
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+    reader.closer = f
+    return reader, nil
+}
+
+// Read binlog file content from r. Magic header is validated immediately.
+func NewFileReader(r io.Reader) (*FileReader, error) {
+    reader := &FileReader{
+        r: bufio.NewReader(r),
+        decoder: NewDecoder(),
+        MaxEventSize: DefaultMaxEventSize,
+    }
+    magic := make([]byte, len(BinlogMagic))
+    _, err := io.ReadFull(reader.r, magic)
+    if err != nil || !bytes.Equal(magic, BinlogMagic) {
+        return nil, fmt.Errorf("not a binlog file: invalid magic header")
+    }
+    reader.position = uint32(len(BinlogMagic))
+    return reader, nil
+}
+
+// Next event. Returns io.EOF at the end of file.
+func (f *FileReader) Next() (Event, error) {
+    header := make([]byte, EventHeaderSize)
+    n, err := io.ReadFull(f.r, header)
+    if err == io.EOF {
+        return nil, io.EOF
+    }
+    if err != nil {
+        return nil, fmt.Errorf("binlog event at %d: truncated header (%d bytes)", f.position, n)
+    }
+
+    size := binary.LittleEndian.Uint32(header[9:])
+    if size < EventHeaderSize || size > f.MaxEventSize {
+        return nil, fmt.Errorf("binlog event at %d: invalid size %d", f.position, size)
+    }
+    // buffer grows with data actually read, so size of truncated
+    // event isn't allocated at once
+    data := bytes.NewBuffer(make([]byte, 0, EventHeaderSize))
+    data.Write(header)
+    _, err = io.CopyN(data, f.r, int64(size - EventHeaderSize))
+    if err != nil {
+        return nil, fmt.Errorf("binlog event at %d: truncated event", f.position)
+    }
+
+    event, err := f.decoder.Decode(data.Bytes())
+    if err != nil {
+        return nil, fmt.Errorf("binlog event at %d: %w", f.position, err)
+    }
+    f.position += size
+    return event, nil
+}
+
+// Offset of next event in file
+func (f *FileReader) Position() uint32 {
+    return f.position
+}
+
+func (f *FileReader) Close() error {
+    if f.closer != nil {
+        return f.closer.Close()
+    }
+    return nil
+}
@@ -0,0 +1,226 @@
+package replication
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "io"
+    "os"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// Binlog in format of MariaDB 10.6 with CRC32 checksums: CREATE TABLE shop.items,
+// then transaction inserting, updating and deleting rows, then rotate
+const fixture = "testdata/mariadb-bin.000001"
+
+func readFixture(t *testing.T) []byte {
+    data, err := os.ReadFile(fixture)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return data
+}
+
+func readAllEvents(t *testing.T, f *FileReader) []Event {
+    events := []Event{}
+    for {
+        event, err := f.Next()
+        if err == io.EOF {
+            return events
+        }
+        if err != nil {
+            t.Fatalf("event %d: %v", len(events), err)
+        }
+        if event.Header().LogPos != f.Position() {
+            t.Fatalf("event %d: log pos %d, reader position %d", len(events), event.Header().LogPos, f.Position())
+        }
+        events = append(events, event)
+    }
+}
+
+func TestFileReaderEvents(t *testing.T) {
+    f, err := OpenFile(fixture)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    events := readAllEvents(t, f)
+
+    types := []uint8{}
+    for _, event := range events {
+        types = append(types, event.Header().Type)
+    }
+    expected := []uint8{
+        FORMAT_DESCRIPTION_EVENT, GTID_EVENT, QUERY_EVENT, GTID_EVENT, QUERY_EVENT,
+        TABLE_MAP_EVENT, WRITE_ROWS_EVENT, TABLE_MAP_EVENT, UPDATE_ROWS_EVENT,
+        TABLE_MAP_EVENT, DELETE_ROWS_EVENT, XID_EVENT, ROTATE_EVENT,
+    }
+    if !reflect.DeepEqual(types, expected) {
+        t.Fatalf("event types %v, expected %v", types, expected)
+    }
+
+    format := events[0].(*FormatDescriptionEvent)
+    if format.ServerVersion != "10.6.0-MariaDB-log" || format.ChecksumAlgorithm != BINLOG_CHECKSUM_ALG_CRC32 {
+        t.Errorf("unexpected format description %+v", format)
+    }
+    if gtid := events[3].(*GTIDEvent).GTID(); gtid != "0-1-7" {
+        t.Errorf("gtid %s, expected 0-1-7", gtid)
+    }
+    query := events[2].(*QueryEvent)
+    if query.Schema != "shop" || !strings.HasPrefix(query.Query, "CREATE TABLE items") {
+        t.Errorf("unexpected query event %+v", query)
+    }
+    table := events[5].(*TableMapEvent)
+    if table.Schema != "shop" || table.Table != "items" ||
+        !reflect.DeepEqual(table.ColumnNames, []string{"id", "name", "price"}) ||
+        !reflect.DeepEqual(table.Unsigned, []bool{true, false, false}) {
+        t.Errorf("unexpected table map %+v", table)
+    }
+    if xid := events[11].(*XIDEvent).XID; xid != 42 {
+        t.Errorf("xid %d, expected 42", xid)
+    }
+    rotate := events[12].(*RotateEvent)
+    if rotate.NextFile != "mariadb-bin.000002" || rotate.Position != 4 {
+        t.Errorf("unexpected rotate event %+v", rotate)
+    }
+    if f.Position() != uint32(len(readFixture(t))) {
+        t.Errorf("position %d after last event, file size %d", f.Position(), len(readFixture(t)))
+    }
+}
+
+func TestFileReaderRows(t *testing.T) {
+    f, err := OpenFile(fixture)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    events := readAllEvents(t, f)
+
+    write := events[6].(*RowsEvent)
+    expected := []RowChange{
+        {After: []interface{}{uint64(1), "apple", "1.25"}},
+        {After: []interface{}{uint64(2), "it's", "10.00"}},
+        {After: []interface{}{uint64(3), nil, "-1.99"}},
+    }
+    if !write.IsWrite() || !reflect.DeepEqual(write.Rows, expected) {
+        t.Errorf("write rows %#v, expected %#v", write.Rows, expected)
+    }
+    update := events[8].(*RowsEvent)
+    expected = []RowChange{
+        {Before: []interface{}{uint64(1), "apple", "1.25"}, After: []interface{}{uint64(1), "pear", "2.50"}},
+    }
+    if !update.IsUpdate() || !reflect.DeepEqual(update.Rows, expected) {
+        t.Errorf("update rows %#v, expected %#v", update.Rows, expected)
+    }
+    del := events[10].(*RowsEvent)
+    expected = []RowChange{
+        {Before: []interface{}{uint64(2), "it's", "10.00"}},
+    }
+    if !del.IsDelete() || !reflect.DeepEqual(del.Rows, expected) {
+        t.Errorf("delete rows %#v, expected %#v", del.Rows, expected)
+    }
+}
+
+func TestFileReaderSQL(t *testing.T) {
+    f, err := OpenFile(fixture)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+
+    statements := []string{}
+    for _, event := range readAllEvents(t, f) {
+        if rows, ok := event.(*RowsEvent); ok {
+            statements = append(statements, rows.SQL()...)
+        }
+    }
+    expected := []string{
+        "INSERT INTO `shop`.`items` (`id`, `name`, `price`) VALUES (1, 'apple', 1.25);",
+        "INSERT INTO `shop`.`items` (`id`, `name`, `price`) VALUES (2, 'it\\'s', 10.00);",
+        "INSERT INTO `shop`.`items` (`id`, `name`, `price`) VALUES (3, NULL, -1.99);",
+        "UPDATE `shop`.`items` SET `id`=1, `name`='pear', `price`=2.50 WHERE `id`=1 AND `name`='apple' AND `price`=1.25;",
+        "DELETE FROM `shop`.`items` WHERE `id`=2 AND `name`='it\\'s' AND `price`=10.00;",
+    }
+    if !reflect.DeepEqual(statements, expected) {
+        t.Errorf("sql:\n%s\nexpected:\n%s", strings.Join(statements, "\n"), strings.Join(expected, "\n"))
+    }
+}
+
+func TestFileReaderInvalidMagic(t *testing.T) {
+    data := readFixture(t)
+    data[1] = 'x'
+    _, err := NewFileReader(bytes.NewReader(data))
+    if err == nil {
+        t.Fatal("expected error for invalid magic header")
+    }
+}
+
+func TestFileReaderChecksumMismatch(t *testing.T) {
+    data := readFixture(t)
+    // corrupt body of XID event
+    data[796 + EventHeaderSize] ^= 0xff
+    f, err := NewFileReader(bytes.NewReader(data))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for {
+        _, err = f.Next()
+        if err != nil {
+            break
+        }
+    }
+    if !errors.Is(err, ErrChecksum) {
+        t.Fatalf("expected checksum error, got %v", err)
+    }
+    if f.Position() != 796 {
+        t.Errorf("position %d, expected 796", f.Position())
+    }
+}
+
+func TestFileReaderInvalidSize(t *testing.T) {
+    for _, size := range []uint32{0, EventHeaderSize - 1, DefaultMaxEventSize + 1, 0xffffffff} {
+        data := readFixture(t)
+        binary.LittleEndian.PutUint32(data[len(BinlogMagic) + 9:], size)
+        f, err := NewFileReader(bytes.NewReader(data))
+        if err != nil {
+            t.Fatal(err)
+        }
+        _, err = f.Next()
+        if err == nil || !strings.Contains(err.Error(), "invalid size") {
+            t.Errorf("size %d: expected invalid size error, got %v", size, err)
+        }
+    }
+
+    f, err := OpenFile(fixture)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    f.MaxEventSize = 100
+    // format description event is 121 bytes long
+    _, err = f.Next()
+    if err == nil || !strings.Contains(err.Error(), "invalid size") {
+        t.Errorf("expected invalid size error with MaxEventSize, got %v", err)
+    }
+}
+
+func TestFileReaderTruncated(t *testing.T) {
+    data := readFixture(t)
+    for _, length := range []int{130, 796 + 10, len(data) - 1} {
+        f, err := NewFileReader(bytes.NewReader(data[:length]))
+        if err != nil {
+            t.Fatal(err)
+        }
+        for {
+            _, err = f.Next()
+            if err != nil {
+                break
+            }
+        }
+        if err == io.EOF || !strings.Contains(err.Error(), "truncated") {
+            t.Errorf("length %d: expected truncated error, got %v", length, err)
+        }
+    }
+}
@@ -0,0 +1,120 @@
+package replication
+
+import (
+    "encoding/binary"
+    "fmt"
+)
+
+var errShortEvent = fmt.Errorf("event is shorter than expected")
+
+// Reads little endian values from event data.
+// Reading past the end sets err and returns zero values,
+// so decoders check error once after reading all fields.
+type reader struct {
+    data []byte
+    pos int
+    err error
+}
+
+func (r *reader) bytes(n int) []byte {
+    if r.err != nil || n < 0 || r.pos + n > len(r.data) {
+        r.err = errShortEvent
+        if n < 0 || n > 8 {
+            return nil
+        }
+        return make([]byte, n)
+    }
+    b := r.data[r.pos:r.pos+n]
+    r.pos += n
+    return b
+}
+
+func (r *reader) skip(n int) {
+    r.bytes(n)
+}
+
+func (r *reader) rest() []byte {
+    if r.err != nil {
+        return nil
+    }
+    b := r.data[r.pos:]
+    r.pos = len(r.data)
+    return b
+}
+
+func (r *reader) left() int {
+    return len(r.data) - r.pos
+}
+
+func (r *reader) readUInt8() uint8 {
+    return r.bytes(1)[0]
+}
+
+func (r *reader) readUInt16() uint16 {
+    return binary.LittleEndian.Uint16(r.bytes(2))
+}
+
+func (r *reader) readUInt24() uint32 {
+    b := r.bytes(3)
+    return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func (r *reader) readUInt32() uint32 {
+    return binary.LittleEndian.Uint32(r.bytes(4))
+}
+
+func (r *reader) readUInt48() uint64 {
+    b := r.bytes(6)
+    return uint64(binary.LittleEndian.Uint32(b)) | uint64(binary.LittleEndian.Uint16(b[4:]))<<32
+}
+
+func (r *reader) readUInt64() uint64 {
+    return binary.LittleEndian.Uint64(r.bytes(8))
+}
+
+// Read unsigned integer of n bytes, little endian
+func (r *reader) readUIntN(n int) uint64 {
+    var v uint64
+    for i, b := range r.bytes(n) {
+        v |= uint64(b) << (8 * i)
+    }
+    return v
+}
+
+// Read unsigned integer of n bytes, big endian
+func (r *reader) readUIntBE(n int) uint64 {
+    var v uint64
+    for _, b := range r.bytes(n) {
+        v = v<<8 | uint64(b)
+    }
+    return v
+}
+
+// See https://mariadb.com/kb/en/protocol-data-types/#length-encoded-integers
+func (r *reader) readUIntLengthEncoded() uint64 {
+    switch v := r.readUInt8(); v {
+    case 0xfc:
+        return uint64(r.readUInt16())
+    case 0xfd:
+        return uint64(r.readUInt24())
+    case 0xfe:
+        return r.readUInt64()
+    default:
+        return uint64(v)
+    }
+}
+
+func (r *reader) readStringNullEnded() string {
+    if r.err != nil {
+        return ""
+    }
+    for i := r.pos; i < len(r.data); i++ {
+        if r.data[i] == 0 {
+            s := string(r.data[r.pos:i])
+            r.pos = i + 1
+            return s
+        }
+    }
+    r.err = errShortEvent
+    return ""
+}
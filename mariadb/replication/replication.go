@@ -0,0 +1,199 @@
+// Binlog replication client.
+//
+// Connection is registered as replica and requests binlog stream from
+// file and position or from MariaDB GTID position. Events are decoded
+// into typed structs and delivered on channel:
+//
+//	stream, err := replication.Start(conn, replication.Config{ServerId: 100, GTID: "0-1-1"})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for event := range stream.Events() {
+//	    if rows, ok := event.(*replication.RowsEvent); ok {
+//	        log.Printf("%s.%s: %d rows", rows.Table.Schema, rows.Table.Table, len(rows.Rows))
+//	    }
+//	}
+//	log.Println(stream.Err())
+//
+// Connection serves only binlog stream afterwards and is closed by Stream.Close.
+// User requires REPLICATION SLAVE privilege.
+//
+// Binlog files copied from server are read with OpenFile using the same decoder.
+package replication
+
+import (
+    "fmt"
+    "io"
+    "regexp"
+    "sync"
+    "time"
+    "github.com/vasflam/lab-mysql-connector/mariadb"
+)
+
+// Tells server that replica understands GTID events
+const MARIA_SLAVE_CAPABILITY_GTID = 4
+
+var gtidPattern = regexp.MustCompile(`^\d+-\d+-\d+(,\d+-\d+-\d+)*$`)
+
+// Replication configuration
+type Config struct {
+    // Unique id among primary and all its replicas
+    ServerId uint32
+    // Reported in SHOW SLAVE HOSTS
+    Hostname string
+    Port uint16
+    // Binlog file and position to start from.
+    // Empty file starts from first available binlog.
+    File string
+    Position uint32
+    // MariaDB GTID position, e.g. '0-1-100,1-2-20'.
+    // Used instead of File and Position when set.
+    GTID string
+    // Server sends HEARTBEAT event when no other events
+    // were sent during this period
+    HeartbeatPeriod time.Duration
+    // Stop stream at the end of last binlog instead of waiting for new events
+    NonBlock bool
+}
+
+// Decoded binlog events stream
+type Stream struct {
+    binlog *mariadb.BinlogStream
+    decoder *Decoder
+    events chan Event
+    done chan struct{}
+    closeOnce sync.Once
+
+    mu sync.Mutex
+    err error
+    file string
+    position uint32
+}
+
+// Register connection as replica and start binlog stream
+func Start(conn *mariadb.Connection, config Config) (*Stream, error) {
+    queries := []string{
+        "SET @master_binlog_checksum = @@global.binlog_checksum",
+        fmt.Sprintf("SET @mariadb_slave_capability = %d", MARIA_SLAVE_CAPABILITY_GTID),
+    }
+    if config.HeartbeatPeriod > 0 {
+        queries = append(queries, fmt.Sprintf("SET @master_heartbeat_period = %d", config.HeartbeatPeriod.Nanoseconds()))
+    }
+
+    file, position := config.File, config.Position
+    if config.GTID != "" {
+        if !gtidPattern.MatchString(config.GTID) {
+            return nil, fmt.Errorf("invalid GTID position '%s'", config.GTID)
+        }
+        queries = append(queries,
+            fmt.Sprintf("SET @slave_connect_state = '%s'", config.GTID),
+            "SET @slave_gtid_strict_mode = 0",
+            "SET @slave_gtid_ignore_duplicates = 0",
+        )
+        file, position = "", 0
+    }
+    if position < 4 && config.GTID == "" {
+        position = 4
+    }
+
+    for _, query := range queries {
+        if _, err := conn.Query(query); err != nil {
+            return nil, err
+        }
+    }
+
+    err := conn.RegisterReplica(config.ServerId, config.Hostname, config.Port)
+    if err != nil {
+        return nil, err
+    }
+
+    var flags uint16
+    if config.NonBlock {
+        flags |= mariadb.BINLOG_DUMP_NON_BLOCK
+    }
+    s := &Stream{
+        binlog: conn.BinlogDump(config.ServerId, file, position, flags),
+        decoder: NewDecoder(),
+        events: make(chan Event, 10),
+        done: make(chan struct{}),
+        file: file,
+        position: position,
+    }
+    go s.run()
+    return s, nil
+}
+
+// Channel of decoded events. Closed when stream ends, see Err.
+func (s *Stream) Events() <-chan Event {
+    return s.events
+}
+
+// Error which ended stream, nil when stream ended normally
+func (s *Stream) Err() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.err
+}
+
+// Binlog file and position of next event. Can be used to resume replication.
+func (s *Stream) Position() (string, uint32) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.file, s.position
+}
+
+// Stop stream and close connection
+func (s *Stream) Close() {
+    s.closeOnce.Do(func() {
+        close(s.done)
+        s.binlog.Close()
+    })
+}
+
+func (s *Stream) run() {
+    defer close(s.events)
+    for {
+        data, err := s.binlog.Next()
+        if err == io.EOF {
+            return
+        }
+        if err == nil {
+            var event Event
+            event, err = s.decoder.Decode(data)
+            if err == nil {
+                s.track(event)
+                select {
+                case s.events <- event:
+                    continue
+                case <-s.done:
+                    return
+                }
+            }
+        }
+
+        select {
+        case <-s.done:
+            // error caused by closed connection
+        default:
+            s.mu.Lock()
+            s.err = err
+            s.mu.Unlock()
+            s.Close()
+        }
+        return
+    }
+}
+
+// Keep position of next event
+func (s *Stream) track(event Event) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if rotate, ok := event.(*RotateEvent); ok {
+        s.file = rotate.NextFile
+        s.position = uint32(rotate.Position)
+        return
+    }
+    if pos := event.Header().LogPos; pos > 0 {
+        s.position = pos
+    }
+}
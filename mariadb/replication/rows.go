@@ -0,0 +1,453 @@
+package replication
+
+import (
+    "fmt"
+    "math"
+    "strings"
+    "time"
+    "github.com/vasflam/lab-mysql-connector/mariadb"
+)
+
+// Optional metadata of TABLE_MAP event.
+// See https://mariadb.com/kb/en/table_map_event/#optional-metadata-fields
+const TABLE_MAP_SIGNEDNESS = 1
+const TABLE_MAP_COLUMN_NAME = 4
+
+// See https://mariadb.com/kb/en/table_map_event/
+func (d *Decoder) decodeTableMap(header EventHeader, r *reader) *TableMapEvent {
+    event := &TableMapEvent{EventHeader: header}
+    event.TableId = d.readTableId(r, header.Type, 8)
+    event.Flags = r.readUInt16()
+    event.Schema = string(r.bytes(int(r.readUInt8())))
+    r.skip(1)
+    event.Table = string(r.bytes(int(r.readUInt8())))
+    r.skip(1)
+
+    count := int(r.readUIntLengthEncoded())
+    event.ColumnTypes = r.bytes(count)
+    if r.err != nil {
+        return event
+    }
+
+    meta := &reader{data: r.bytes(int(r.readUIntLengthEncoded()))}
+    event.ColumnMeta = make([]uint16, count)
+    for i, kind := range event.ColumnTypes {
+        event.ColumnMeta[i] = readColumnMeta(meta, kind)
+    }
+    event.NullBitmap = r.bytes((count + 7) / 8)
+    event.Unsigned = make([]bool, count)
+
+    for r.err == nil && r.left() > 0 {
+        kind := r.readUInt8()
+        field := &reader{data: r.bytes(int(r.readUIntLengthEncoded()))}
+        switch kind {
+        case TABLE_MAP_SIGNEDNESS:
+            bitmap := field.rest()
+            numeric := 0
+            for i, columnType := range event.ColumnTypes {
+                if !isNumericType(columnType) {
+                    continue
+                }
+                if numeric / 8 < len(bitmap) {
+                    event.Unsigned[i] = bitmap[numeric / 8] & (0x80 >> (numeric % 8)) != 0
+                }
+                numeric++
+            }
+        case TABLE_MAP_COLUMN_NAME:
+            for field.err == nil && field.left() > 0 {
+                name := field.bytes(int(field.readUIntLengthEncoded()))
+                event.ColumnNames = append(event.ColumnNames, string(name))
+            }
+        }
+    }
+    if meta.err != nil && r.err == nil {
+        r.err = meta.err
+    }
+    return event
+}
+
+func isNumericType(kind byte) bool {
+    switch kind {
+    case mariadb.MYSQL_TYPE_TINY, mariadb.MYSQL_TYPE_SHORT, mariadb.MYSQL_TYPE_INT24,
+        mariadb.MYSQL_TYPE_LONG, mariadb.MYSQL_TYPE_LONGLONG, mariadb.MYSQL_TYPE_NEWDECIMAL,
+        mariadb.MYSQL_TYPE_FLOAT, mariadb.MYSQL_TYPE_DOUBLE:
+        return true
+    }
+    return false
+}
+
+func readColumnMeta(r *reader, kind byte) uint16 {
+    switch kind {
+    case mariadb.MYSQL_TYPE_FLOAT, mariadb.MYSQL_TYPE_DOUBLE, mariadb.MYSQL_TYPE_BLOB,
+        mariadb.MYSQL_TYPE_GEOMETRY, mariadb.MYSQL_TYPE_JSON, mariadb.MYSQL_TYPE_TIMESTAMP2,
+        mariadb.MYSQL_TYPE_DATETIME2, mariadb.MYSQL_TYPE_TIME2:
+        return uint16(r.readUInt8())
+    case mariadb.MYSQL_TYPE_VARCHAR, mariadb.MYSQL_TYPE_VAR_STRING, mariadb.MYSQL_TYPE_BIT:
+        return r.readUInt16()
+    case mariadb.MYSQL_TYPE_STRING, mariadb.MYSQL_TYPE_NEWDECIMAL:
+        // real type or precision in first byte
+        return uint16(r.readUIntBE(2))
+    }
+    return 0
+}
+
+// See https://mariadb.com/kb/en/rows_event_v1v2/
+func (d *Decoder) decodeRows(header EventHeader, r *reader) (*RowsEvent, error) {
+    event := &RowsEvent{EventHeader: header}
+    v2 := header.Type >= WRITE_ROWS_EVENT
+    if v2 {
+        event.TableId = d.readTableId(r, header.Type, 10)
+    } else {
+        event.TableId = d.readTableId(r, header.Type, 8)
+    }
+    event.Flags = r.readUInt16()
+    if v2 {
+        extraLength := int(r.readUInt16())
+        r.skip(extraLength - 2)
+    }
+
+    event.ColumnCount = int(r.readUIntLengthEncoded())
+    bitmapSize := (event.ColumnCount + 7) / 8
+    before := r.bytes(bitmapSize)
+    after := before
+    if event.IsUpdate() {
+        after = r.bytes(bitmapSize)
+    }
+    if r.err != nil {
+        return nil, r.err
+    }
+    if !event.IsWrite() {
+        event.BeforeColumns = bitmapColumns(before, event.ColumnCount)
+    }
+    if !event.IsDelete() {
+        event.AfterColumns = bitmapColumns(after, event.ColumnCount)
+    }
+    if r.left() == 0 {
+        return event, nil
+    }
+
+    table, ok := d.tables[event.TableId]
+    if !ok {
+        return nil, fmt.Errorf("rows event for unknown table id %d", event.TableId)
+    }
+    if len(table.ColumnTypes) < event.ColumnCount {
+        return nil, fmt.Errorf("rows event has %d columns, table %s.%s has %d",
+            event.ColumnCount, table.Schema, table.Table, len(table.ColumnTypes))
+    }
+    event.Table = table
+
+    for r.err == nil && r.left() > 0 {
+        var change RowChange
+        var err error
+        if event.IsWrite() {
+            change.After, err = readRow(r, table, after, event.ColumnCount)
+        } else {
+            change.Before, err = readRow(r, table, before, event.ColumnCount)
+            if err == nil && event.IsUpdate() {
+                change.After, err = readRow(r, table, after, event.ColumnCount)
+            }
+        }
+        if err != nil {
+            return nil, fmt.Errorf("table %s.%s: %w", table.Schema, table.Table, err)
+        }
+        event.Rows = append(event.Rows, change)
+    }
+    return event, r.err
+}
+
+func bitSet(bitmap []byte, i int) bool {
+    return bitmap[i / 8] & (1 << (i % 8)) != 0
+}
+
+func bitmapColumns(bitmap []byte, count int) []bool {
+    columns := make([]bool, count)
+    for i := range columns {
+        columns[i] = bitSet(bitmap, i)
+    }
+    return columns
+}
+
+// Read row image. Only columns set in present bitmap are stored.
+func readRow(r *reader, table *TableMapEvent, present []byte, count int) ([]interface{}, error) {
+    presentCount := 0
+    for i := 0; i < count; i++ {
+        if bitSet(present, i) {
+            presentCount++
+        }
+    }
+
+    row := make([]interface{}, count)
+    nulls := r.bytes((presentCount + 7) / 8)
+    n := 0
+    for i := 0; i < count && r.err == nil; i++ {
+        if !bitSet(present, i) {
+            continue
+        }
+        isNull := bitSet(nulls, n)
+        n++
+        if isNull {
+            continue
+        }
+        value, err := readValue(r, table.ColumnTypes[i], table.ColumnMeta[i], table.Unsigned[i])
+        if err != nil {
+            return nil, fmt.Errorf("column %d: %w", i + 1, err)
+        }
+        row[i] = value
+    }
+    return row, r.err
+}
+
+// Decode column value of row image. Integers are returned as int64 or
+// uint64, DECIMAL and temporal types as strings in SQL format,
+// BLOB and TEXT as []byte.
+func readValue(r *reader, kind byte, meta uint16, unsigned bool) (interface{}, error) {
+    switch kind {
+    case mariadb.MYSQL_TYPE_NULL:
+        return nil, nil
+    case mariadb.MYSQL_TYPE_TINY:
+        return readInteger(r, 1, unsigned), nil
+    case mariadb.MYSQL_TYPE_SHORT:
+        return readInteger(r, 2, unsigned), nil
+    case mariadb.MYSQL_TYPE_INT24:
+        return readInteger(r, 3, unsigned), nil
+    case mariadb.MYSQL_TYPE_LONG:
+        return readInteger(r, 4, unsigned), nil
+    case mariadb.MYSQL_TYPE_LONGLONG:
+        return readInteger(r, 8, unsigned), nil
+    case mariadb.MYSQL_TYPE_FLOAT:
+        return float64(math.Float32frombits(r.readUInt32())), nil
+    case mariadb.MYSQL_TYPE_DOUBLE:
+        return math.Float64frombits(r.readUInt64()), nil
+    case mariadb.MYSQL_TYPE_NEWDECIMAL:
+        return readDecimal(r, int(meta >> 8), int(meta & 0xff)), nil
+    case mariadb.MYSQL_TYPE_YEAR:
+        year := int64(r.readUInt8())
+        if year != 0 {
+            year += 1900
+        }
+        return year, nil
+    case mariadb.MYSQL_TYPE_DATE:
+        v := r.readUInt24()
+        return fmt.Sprintf("%04d-%02d-%02d", v >> 9, (v >> 5) & 15, v & 31), nil
+    case mariadb.MYSQL_TYPE_TIME:
+        v := int64(r.readUInt24())
+        sign := ""
+        if v & 0x800000 != 0 {
+            v = 0x1000000 - v
+            sign = "-"
+        }
+        return fmt.Sprintf("%s%02d:%02d:%02d", sign, v / 10000, v % 10000 / 100, v % 100), nil
+    case mariadb.MYSQL_TYPE_TIME2:
+        return readTime2(r, int(meta)), nil
+    case mariadb.MYSQL_TYPE_DATETIME:
+        v := r.readUInt64()
+        d, t := v / 1000000, v % 1000000
+        return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d",
+            d / 10000, d % 10000 / 100, d % 100, t / 10000, t % 10000 / 100, t % 100), nil
+    case mariadb.MYSQL_TYPE_DATETIME2:
+        return readDateTime2(r, int(meta)), nil
+    case mariadb.MYSQL_TYPE_TIMESTAMP:
+        return formatTimestamp(int64(r.readUInt32()), 0, 0), nil
+    case mariadb.MYSQL_TYPE_TIMESTAMP2:
+        sec := int64(r.readUIntBE(4))
+        return formatTimestamp(sec, readFraction(r, int(meta)), int(meta)), nil
+    case mariadb.MYSQL_TYPE_VARCHAR, mariadb.MYSQL_TYPE_VAR_STRING:
+        return readString(r, int(meta)), nil
+    case mariadb.MYSQL_TYPE_STRING:
+        return readStringColumn(r, meta)
+    case mariadb.MYSQL_TYPE_BIT:
+        bits := int(meta >> 8) * 8 + int(meta & 0xff)
+        return r.readUIntBE((bits + 7) / 8), nil
+    case mariadb.MYSQL_TYPE_BLOB, mariadb.MYSQL_TYPE_GEOMETRY, mariadb.MYSQL_TYPE_JSON:
+        length := int(r.readUIntN(int(meta)))
+        return r.bytes(length), nil
+    }
+    return nil, fmt.Errorf("unsupported column type %d", kind)
+}
+
+func readInteger(r *reader, size int, unsigned bool) interface{} {
+    v := r.readUIntN(size)
+    if unsigned {
+        return v
+    }
+    shift := 64 - size * 8
+    return int64(v << shift) >> shift
+}
+
+// String length is prefixed with one byte when maximal length is less than 256
+func readString(r *reader, maxLength int) string {
+    if maxLength < 256 {
+        return string(r.bytes(int(r.readUInt8())))
+    }
+    return string(r.bytes(int(r.readUInt16())))
+}
+
+// CHAR, ENUM and SET columns have STRING type in table map,
+// real type and length are packed into metadata
+func readStringColumn(r *reader, meta uint16) (interface{}, error) {
+    kind := byte(mariadb.MYSQL_TYPE_STRING)
+    length := int(meta)
+    if meta >= 256 {
+        b0, b1 := byte(meta >> 8), byte(meta & 0xff)
+        if b0 & 0x30 != 0x30 {
+            length = int(b1) | int((b0 & 0x30) ^ 0x30) << 4
+            kind = b0 | 0x30
+        } else {
+            length = int(b1)
+            kind = b0
+        }
+    }
+
+    switch kind {
+    case mariadb.MYSQL_TYPE_ENUM:
+        if length != 1 && length != 2 {
+            return nil, fmt.Errorf("unsupported ENUM size %d", length)
+        }
+        return int64(r.readUIntN(length)), nil
+    case mariadb.MYSQL_TYPE_SET:
+        return int64(r.readUIntN(length)), nil
+    case mariadb.MYSQL_TYPE_STRING:
+        return readString(r, length), nil
+    }
+    return nil, fmt.Errorf("unsupported string column type %d", kind)
+}
+
+var digitsToBytes = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// Decimal is stored as groups of 9 digits in 4 bytes, leading and
+// trailing groups of less digits use less bytes. Sign is kept in
+// highest bit, negative values have all bits inverted.
+func readDecimal(r *reader, precision, scale int) string {
+    integral := precision - scale
+    intg0, intg0x := integral / 9, integral % 9
+    frac0, frac0x := scale / 9, scale % 9
+    size := intg0 * 4 + digitsToBytes[intg0x] + frac0 * 4 + digitsToBytes[frac0x]
+    raw := r.bytes(size)
+    if r.err != nil || size == 0 {
+        return "0"
+    }
+
+    data := make([]byte, size)
+    copy(data, raw)
+    negative := data[0] & 0x80 == 0
+    data[0] ^= 0x80
+    if negative {
+        for i := range data {
+            data[i] ^= 0xff
+        }
+    }
+    group := &reader{data: data}
+
+    var integer strings.Builder
+    if intg0x > 0 {
+        fmt.Fprintf(&integer, "%d", group.readUIntBE(digitsToBytes[intg0x]))
+    }
+    for i := 0; i < intg0; i++ {
+        fmt.Fprintf(&integer, "%09d", group.readUIntBE(4))
+    }
+
+    var result strings.Builder
+    if negative {
+        result.WriteString("-")
+    }
+    digits := strings.TrimLeft(integer.String(), "0")
+    if digits == "" {
+        digits = "0"
+    }
+    result.WriteString(digits)
+    if scale > 0 {
+        result.WriteString(".")
+        for i := 0; i < frac0; i++ {
+            fmt.Fprintf(&result, "%09d", group.readUIntBE(4))
+        }
+        if frac0x > 0 {
+            fmt.Fprintf(&result, "%0*d", frac0x, group.readUIntBE(digitsToBytes[frac0x]))
+        }
+    }
+    return result.String()
+}
+
+// Fractional seconds part of temporal types, returns microseconds
+func readFraction(r *reader, fsp int) int {
+    switch fsp {
+    case 1, 2:
+        return int(r.readUIntBE(1)) * 10000
+    case 3, 4:
+        return int(r.readUIntBE(2)) * 100
+    case 5, 6:
+        return int(r.readUIntBE(3))
+    }
+    return 0
+}
+
+func formatFraction(micro, fsp int) string {
+    if fsp <= 0 {
+        return ""
+    }
+    if fsp > 6 {
+        fsp = 6
+    }
+    return "." + fmt.Sprintf("%06d", micro)[:fsp]
+}
+
+func formatTimestamp(sec int64, micro, fsp int) string {
+    if sec == 0 && micro == 0 {
+        return "0000-00-00 00:00:00" + formatFraction(0, fsp)
+    }
+    return time.Unix(sec, 0).UTC().Format("2006-01-02 15:04:05") + formatFraction(micro, fsp)
+}
+
+// See TIME_FSP_BINARY in MariaDB sources, mysys/my_time.c
+func readTime2(r *reader, fsp int) string {
+    const intOffset = 0x800000
+    var packed int64
+    switch fsp {
+    case 1, 2:
+        intPart := int64(r.readUIntBE(3)) - intOffset
+        frac := int64(r.readUIntBE(1))
+        if intPart < 0 && frac > 0 {
+            intPart++
+            frac -= 0x100
+        }
+        packed = intPart << 24 + frac * 10000
+    case 3, 4:
+        intPart := int64(r.readUIntBE(3)) - intOffset
+        frac := int64(r.readUIntBE(2))
+        if intPart < 0 && frac > 0 {
+            intPart++
+            frac -= 0x10000
+        }
+        packed = intPart << 24 + frac * 100
+    case 5, 6:
+        packed = int64(r.readUIntBE(6)) - intOffset << 24
+    default:
+        packed = (int64(r.readUIntBE(3)) - intOffset) << 24
+    }
+
+    sign := ""
+    if packed < 0 {
+        sign = "-"
+        packed = -packed
+    }
+    hms := packed >> 24
+    micro := int(packed % (1 << 24))
+    return fmt.Sprintf("%s%02d:%02d:%02d%s", sign,
+        (hms >> 12) % (1 << 10), (hms >> 6) % (1 << 6), hms % (1 << 6),
+        formatFraction(micro, fsp))
+}
+
+// See DATETIME_FSP_BINARY in MariaDB sources, mysys/my_time.c
+func readDateTime2(r *reader, fsp int) string {
+    intPart := int64(r.readUIntBE(5)) - 0x8000000000
+    micro := readFraction(r, fsp)
+    if intPart < 0 {
+        intPart = -intPart
+    }
+    ymd := intPart >> 17
+    ym := ymd >> 5
+    hms := intPart % (1 << 17)
+    return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d%s",
+        ym / 13, ym % 13, ymd % (1 << 5),
+        hms >> 12, (hms >> 6) % (1 << 6), hms % (1 << 6),
+        formatFraction(micro, fsp))
+}
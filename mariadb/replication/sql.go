@@ -0,0 +1,114 @@
+package replication
+
+import (
+    "encoding/hex"
+    "fmt"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+    "github.com/vasflam/lab-mysql-connector/mariadb"
+)
+
+// Render row changes as SQL statements, one statement per row.
+// Column names are known only when server has binlog_row_metadata=FULL,
+// otherwise columns are named @1, @2... like mysqlbinlog does.
+func (e *RowsEvent) SQL() []string {
+    if e.Table == nil {
+        return nil
+    }
+    table := quoteIdentifier(e.Table.Schema) + "." + quoteIdentifier(e.Table.Table)
+    statements := []string{}
+    for _, row := range e.Rows {
+        switch {
+        case e.IsWrite():
+            names := []string{}
+            values := []string{}
+            for i, included := range e.AfterColumns {
+                if included {
+                    names = append(names, e.columnName(i))
+                    values = append(values, e.literal(i, row.After[i]))
+                }
+            }
+            statements = append(statements, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);",
+                table, strings.Join(names, ", "), strings.Join(values, ", ")))
+        case e.IsUpdate():
+            assignments := []string{}
+            for i, included := range e.AfterColumns {
+                if included {
+                    assignments = append(assignments, e.columnName(i) + "=" + e.literal(i, row.After[i]))
+                }
+            }
+            statements = append(statements, fmt.Sprintf("UPDATE %s SET %s WHERE %s;",
+                table, strings.Join(assignments, ", "), e.condition(row.Before)))
+        case e.IsDelete():
+            statements = append(statements, fmt.Sprintf("DELETE FROM %s WHERE %s;",
+                table, e.condition(row.Before)))
+        }
+    }
+    return statements
+}
+
+// Condition matching before image
+func (e *RowsEvent) condition(row []interface{}) string {
+    conditions := []string{}
+    for i, included := range e.BeforeColumns {
+        if !included {
+            continue
+        }
+        if row[i] == nil {
+            conditions = append(conditions, e.columnName(i) + " IS NULL")
+        } else {
+            conditions = append(conditions, e.columnName(i) + "=" + e.literal(i, row[i]))
+        }
+    }
+    return strings.Join(conditions, " AND ")
+}
+
+func (e *RowsEvent) columnName(i int) string {
+    if i < len(e.Table.ColumnNames) {
+        return quoteIdentifier(e.Table.ColumnNames[i])
+    }
+    return fmt.Sprintf("@%d", i + 1)
+}
+
+// Format decoded column value as SQL literal
+func (e *RowsEvent) literal(i int, value interface{}) string {
+    switch v := value.(type) {
+    case nil:
+        return "NULL"
+    case int64:
+        return strconv.FormatInt(v, 10)
+    case uint64:
+        return strconv.FormatUint(v, 10)
+    case float64:
+        return strconv.FormatFloat(v, 'g', -1, 64)
+    case string:
+        if e.Table.ColumnTypes[i] == mariadb.MYSQL_TYPE_NEWDECIMAL {
+            return v
+        }
+        return quoteString(v)
+    case []byte:
+        if utf8.Valid(v) {
+            return quoteString(string(v))
+        }
+        return "X'" + hex.EncodeToString(v) + "'"
+    }
+    return quoteString(fmt.Sprint(value))
+}
+
+func quoteIdentifier(name string) string {
+    return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+var stringEscaper = strings.NewReplacer(
+    "\\", "\\\\",
+    "'", "\\'",
+    "\x00", "\\0",
+    "\n", "\\n",
+    "\r", "\\r",
+    "\x1a", "\\Z",
+)
+
+func quoteString(s string) string {
+    return "'" + stringEscaper.Replace(s) + "'"
+}
@@ -0,0 +1,46 @@
+package mariadb
+
+// Result of statement which doesn't return rows.
+// See https://mariadb.com/kb/en/ok_packet/
+type Result struct {
+    AffectedRows uint64
+    LastInsertId uint64
+    Warnings uint16
+    // Server status flags, e.g. SERVER_STATUS_IN_TRANS
+    Status uint16
+    // Human readable information, e.g. "Rows matched: 1  Changed: 1  Warnings: 0"
+    Info string
+}
+
+func newResult(ok *okPacket) Result {
+    return Result{
+        AffectedRows: ok.affectedRows,
+        LastInsertId: ok.lastInsertId,
+        Warnings: ok.warnings,
+        Status: ok.status,
+        Info: ok.info,
+    }
+}
+
+// Store result returned by deprecated LastInsertId and AffectedRows
+func (c *Connection) setLastResult(result Result) {
+    c.lastResult.Store(result)
+}
+
+// Id generated by last statement run on connection.
+//
+// Deprecated: value is shared by all goroutines using connection,
+// use Result returned by Exec.
+func (c *Connection) LastInsertId() int {
+    result, _ := c.lastResult.Load().(Result)
+    return int(result.LastInsertId)
+}
+
+// Number of rows changed by last statement run on connection.
+//
+// Deprecated: value is shared by all goroutines using connection,
+// use Result returned by Exec.
+func (c *Connection) AffectedRows() int {
+    result, _ := c.lastResult.Load().(Result)
+    return int(result.AffectedRows)
+}
@@ -0,0 +1,314 @@
+package mariadb
+
+import (
+    "context"
+    "strings"
+    "sync"
+)
+
+// Strategies of replica selection
+const BALANCE_ROUND_ROBIN = 0
+const BALANCE_LEAST_CONNECTIONS = 1
+const BALANCE_WEIGHTED = 2
+
+// Replica pool of Router
+type RouterReplica struct {
+    Pool *Pool
+    // Share of queries with BALANCE_WEIGHTED, 1 when not positive
+    Weight int
+}
+
+type RouterOptions struct {
+    // BALANCE_ROUND_ROBIN (default), BALANCE_LEAST_CONNECTIONS or BALANCE_WEIGHTED
+    Balance int
+}
+
+// Read/write splitting across primary and replica pools. Statements starting
+// with SELECT or SHOW are sent to replicas, other statements, locking reads
+// (FOR UPDATE, LOCK IN SHARE MODE) and SELECT ... INTO to primary. Leading /* primary */ or
+// /* replica */ comment forces target. Primary is used when there are no
+// replicas or none of them can give connection.
+//
+//	router := mariadb.NewRouter(primary, []mariadb.RouterReplica{
+//	    {Pool: replica1, Weight: 2},
+//	    {Pool: replica2, Weight: 1},
+//	}, mariadb.RouterOptions{Balance: mariadb.BALANCE_WEIGHTED})
+//	defer router.Close()
+//	rows, err := router.QueryContext(ctx, "SELECT * FROM numbers")
+type Router struct {
+    mu sync.Mutex
+    primary *Pool
+    replicas []RouterReplica
+    options RouterOptions
+    // next replica of round robin
+    next int
+    // current weights of smooth weighted round robin
+    current []int
+}
+
+func NewRouter(primary *Pool, replicas []RouterReplica, options RouterOptions) *Router {
+    r := &Router{
+        primary: primary,
+        replicas: make([]RouterReplica, len(replicas)),
+        options: options,
+        current: make([]int, len(replicas)),
+    }
+    copy(r.replicas, replicas)
+    for i := range r.replicas {
+        if r.replicas[i].Weight <= 0 {
+            r.replicas[i].Weight = 1
+        }
+    }
+    return r
+}
+
+// Pool of primary
+func (r *Router) Primary() *Pool {
+    return r.primary
+}
+
+// Run query on replica or primary, see routing rules of Router
+func (r *Router) QueryContext(ctx context.Context, query string, args ...interface{}) (QueryResultRows, error) {
+    var rows QueryResultRows
+    err := r.do(ctx, isReadQuery(query), func(conn *Connection) error {
+        var err error
+        rows, err = conn.QueryContext(ctx, query, args...)
+        return err
+    })
+    return rows, err
+}
+
+// Run statement on replica or primary, see routing rules of Router
+func (r *Router) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+    var result Result
+    err := r.do(ctx, isReadQuery(query), func(conn *Connection) error {
+        var err error
+        result, err = conn.ExecContext(ctx, query, args...)
+        return err
+    })
+    return result, err
+}
+
+// Run fn with connection of primary
+func (r *Router) Do(ctx context.Context, fn func(conn *Connection) error) error {
+    return r.primary.Do(ctx, fn)
+}
+
+// Run fn with connection of replica, primary is used when no replica is available
+func (r *Router) DoReplica(ctx context.Context, fn func(conn *Connection) error) error {
+    return r.do(ctx, true, fn)
+}
+
+// Run transaction on primary, read-only transactions run on replica
+func (r *Router) RunInTx(ctx context.Context, options TxOptions, fn func(tx *Tx) error) error {
+    return r.do(ctx, options.ReadOnly, func(conn *Connection) error {
+        return conn.RunInTx(ctx, options, fn)
+    })
+}
+
+// Close primary and replica pools
+func (r *Router) Close() error {
+    err := r.primary.Close()
+    for _, replica := range r.replicas {
+        if closeErr := replica.Pool.Close(); closeErr != nil && err == nil {
+            err = closeErr
+        }
+    }
+    return err
+}
+
+func (r *Router) do(ctx context.Context, read bool, fn func(conn *Connection) error) error {
+    if !read {
+        return r.primary.Do(ctx, fn)
+    }
+    for _, i := range r.replicaOrder() {
+        pool := r.replicas[i].Pool
+        conn, err := pool.Get(ctx)
+        if err != nil {
+            if ctx.Err() != nil {
+                return err
+            }
+            continue
+        }
+        defer pool.Put(conn)
+        return fn(conn)
+    }
+    return r.primary.Do(ctx, fn)
+}
+
+// Replicas in order they are tried, selected one goes first
+func (r *Router) replicaOrder() []int {
+    count := len(r.replicas)
+    if count == 0 {
+        return nil
+    }
+    var first int
+    switch r.options.Balance {
+    case BALANCE_LEAST_CONNECTIONS:
+        // ties are broken in round robin order
+        start := r.nextRoundRobin()
+        least := -1
+        for k := 0; k < count; k++ {
+            i := (start + k) % count
+            inUse := r.replicas[i].Pool.Stats().InUse
+            if least < 0 || inUse < least {
+                first, least = i, inUse
+            }
+        }
+    case BALANCE_WEIGHTED:
+        first = r.nextWeighted()
+    default:
+        first = r.nextRoundRobin()
+    }
+
+    order := make([]int, count)
+    for i := range order {
+        order[i] = (first + i) % count
+    }
+    return order
+}
+
+func (r *Router) nextRoundRobin() int {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    next := r.next
+    r.next = (next + 1) % len(r.replicas)
+    return next
+}
+
+// Smooth weighted round robin, replicas are picked proportionally
+// to weights without bursts
+func (r *Router) nextWeighted() int {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    total := 0
+    best := 0
+    for i, replica := range r.replicas {
+        r.current[i] += replica.Weight
+        total += replica.Weight
+        if r.current[i] > r.current[best] {
+            best = i
+        }
+    }
+    r.current[best] -= total
+    return best
+}
+
+// Query can be sent to replica: SELECT or SHOW without locking or INTO
+// clause, or statement with /* replica */ hint
+func isReadQuery(query string) bool {
+    i := 0
+    for i < len(query) {
+        switch {
+        case query[i] == ' ' || query[i] == '\t' || query[i] == '\n' || query[i] == '\r':
+            i++
+        case strings.HasPrefix(query[i:], "/*"):
+            end := strings.Index(query[i + 2:], "*/")
+            if end < 0 {
+                return false
+            }
+            comment := query[i + 2:i + 2 + end]
+            if strings.HasPrefix(comment, "!") || strings.HasPrefix(comment, "M!") {
+                // executable comment
+                return false
+            }
+            switch strings.ToLower(strings.TrimSpace(comment)) {
+            case "primary":
+                return false
+            case "replica":
+                return true
+            }
+            i += end + 4
+        case strings.HasPrefix(query[i:], "-- ") || query[i] == '#':
+            end := strings.IndexByte(query[i:], '\n')
+            if end < 0 {
+                return false
+            }
+            i += end + 1
+        default:
+            return isReadStatement(query[i:])
+        }
+    }
+    return false
+}
+
+// Functions using locks, sequences or session state of connection.
+// Queries calling them are sent to primary.
+var primaryFunctions = map[string]bool{
+    "LAST_INSERT_ID": true,
+    "FOUND_ROWS": true,
+    "ROW_COUNT": true,
+    "GET_LOCK": true,
+    "RELEASE_LOCK": true,
+    "RELEASE_ALL_LOCKS": true,
+    "IS_FREE_LOCK": true,
+    "IS_USED_LOCK": true,
+    "NEXTVAL": true,
+    "LASTVAL": true,
+    "SETVAL": true,
+}
+
+// SELECT without locking or INTO clause, or SHOW. SELECT calling functions
+// of primaryFunctions, using NEXT VALUE FOR sequence or assigning user
+// variable with := isn't read. Keywords are matched outside of string
+// literals, quoted identifiers and comments.
+func isReadStatement(query string) bool {
+    words := []string{}
+    for i := 0; i < len(query); {
+        if j := skipLiteral(query, i, false); j > i {
+            if strings.HasPrefix(query[i:], "/*!") || strings.HasPrefix(query[i:], "/*M!") {
+                // executable comment
+                return false
+            }
+            i = j
+            continue
+        }
+        if strings.HasPrefix(query[i:], ":=") {
+            return false
+        }
+        if !isNameChar(query[i]) {
+            i++
+            continue
+        }
+        j := i
+        for j < len(query) && isNameChar(query[j]) {
+            j++
+        }
+        word := strings.ToUpper(query[i:j])
+        if primaryFunctions[word] && strings.HasPrefix(strings.TrimLeft(query[j:], " \t\r\n"), "(") {
+            return false
+        }
+        words = append(words, word)
+        i = j
+    }
+    if len(words) == 0 {
+        return false
+    }
+    switch words[0] {
+    case "SHOW":
+        return true
+    case "SELECT":
+        for i := range words {
+            if hasWords(words[i:], "INTO") || hasWords(words[i:], "FOR", "UPDATE") ||
+                hasWords(words[i:], "LOCK", "IN", "SHARE", "MODE") ||
+                hasWords(words[i:], "NEXT", "VALUE", "FOR") || hasWords(words[i:], "PREVIOUS", "VALUE", "FOR") {
+                return false
+            }
+        }
+        return true
+    }
+    return false
+}
+
+// Words start with keywords
+func hasWords(words []string, keywords ...string) bool {
+    if len(words) < len(keywords) {
+        return false
+    }
+    for i, keyword := range keywords {
+        if words[i] != keyword {
+            return false
+        }
+    }
+    return true
+}
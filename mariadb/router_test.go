@@ -0,0 +1,59 @@
+package mariadb
+
+import (
+    "testing"
+)
+
+func TestIsReadQuery(t *testing.T) {
+    for query, expected := range map[string]bool{
+        "SELECT * FROM t": true,
+        "  select a FROM t WHERE id = 1": true,
+        "SHOW TABLES": true,
+        "/* replica */ UPDATE t SET a = 1": true,
+        "/* primary */ SELECT 1": false,
+        "-- comment\nSELECT 1": true,
+        "UPDATE t SET a = 1": false,
+        "SELECT a INTO @x FROM t": false,
+        "SELECT a\nINTO @x FROM t": false,
+        "SELECT a\tINTO OUTFILE '/tmp/a' FROM t": false,
+        "SELECT * FROM t WHERE id = 1 FOR UPDATE": false,
+        "SELECT * FROM t WHERE id = 1 FOR\n  UPDATE": false,
+        "SELECT * FROM t FOR /* lock */ UPDATE": false,
+        "SELECT * FROM t LOCK IN SHARE\nMODE": false,
+        "SELECT * FROM t WHERE name = 'x into y'": true,
+        "SELECT * FROM t WHERE name = \"wait FOR UPDATE\"": true,
+        "SELECT `into` FROM t": true,
+        "SELECT a FROM t -- INTO @x\n": true,
+        "SELECT a FROM t # for update": true,
+        "SELECT a FROM t /* lock in share mode */": true,
+        "SELECT a FROM t WHERE b = 'it\\'s into'": true,
+        "SELECT intox, into_y FROM t": true,
+        "SELECT a /*!INTO @x */ FROM t": false,
+        "SELECT LAST_INSERT_ID()": false,
+        "select last_insert_id ( )": false,
+        "SELECT FOUND_ROWS()": false,
+        "SELECT ROW_COUNT()": false,
+        "SELECT GET_LOCK('a', 10)": false,
+        "SELECT RELEASE_LOCK('a')": false,
+        "SELECT IS_FREE_LOCK('a'), IS_USED_LOCK('a')": false,
+        "SELECT NEXTVAL(seq)": false,
+        "SELECT LASTVAL(seq)": false,
+        "SELECT SETVAL(seq, 10)": false,
+        "SELECT NEXT VALUE FOR seq": false,
+        "SELECT PREVIOUS VALUE FOR db.seq": false,
+        "SELECT @a := MAX(id) FROM t": false,
+        "SELECT @a:=1": false,
+        "SELECT last_insert_id FROM t": true,
+        "SELECT `get_lock`(1)": true,
+        "SELECT 'LAST_INSERT_ID()', \"NEXT VALUE FOR seq\" FROM t": true,
+        "SELECT a FROM t /* GET_LOCK('a', 1) */": true,
+        "SELECT a FROM t WHERE b = ':='": true,
+        "SELECT a FROM t WHERE b = @a": true,
+        "SELECT next_value FROM t": true,
+        "": false,
+    } {
+        if isReadQuery(query) != expected {
+            t.Errorf("isReadQuery(%q) != %v", query, expected)
+        }
+    }
+}
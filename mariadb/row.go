@@ -0,0 +1,102 @@
+package mariadb
+
+import (
+    "strings"
+)
+
+// Row of result set. Unlike QueryResultRow it keeps column order
+// and columns with duplicate names, e.g. two "id" columns of joined tables.
+type Row struct {
+    meta *rowMeta
+    values []interface{}
+}
+
+// Column metadata shared by all rows of result set
+type rowMeta struct {
+    columns []ColumnType
+    names map[string]int
+    qualified map[string]int
+}
+
+func newRowMeta(columns []tableColumn) *rowMeta {
+    meta := &rowMeta{
+        columns: columnTypes(columns),
+        names: map[string]int{},
+        qualified: map[string]int{},
+    }
+    for i, column := range meta.columns {
+        if _, ok := meta.names[column.Name]; !ok {
+            meta.names[column.Name] = i
+        }
+        for _, table := range []string{column.Table, column.OrgTable} {
+            if table == "" {
+                continue
+            }
+            name := table + "." + column.Name
+            if _, ok := meta.qualified[name]; !ok {
+                meta.qualified[name] = i
+            }
+        }
+    }
+    return meta
+}
+
+// Find column by alias or by table-qualified name "table.column".
+// Returns -1 if there is no such column.
+func (m *rowMeta) index(name string) int {
+    if i, ok := m.names[name]; ok {
+        return i
+    }
+    if strings.Contains(name, ".") {
+        if i, ok := m.qualified[name]; ok {
+            return i
+        }
+    }
+    return -1
+}
+
+// Number of columns
+func (r Row) Len() int {
+    return len(r.values)
+}
+
+// Metadata of row columns
+func (r Row) ColumnTypes() []ColumnType {
+    return r.meta.columns
+}
+
+// Values in column order
+func (r Row) Values() []interface{} {
+    return r.values
+}
+
+// Value of i-th column
+func (r Row) Index(i int) interface{} {
+    return r.values[i]
+}
+
+// Value of column with given alias or table-qualified name "table.column".
+// When several columns have the same alias the first one is returned.
+func (r Row) Lookup(name string) (interface{}, bool) {
+    i := r.meta.index(name)
+    if i < 0 {
+        return nil, false
+    }
+    return r.values[i], true
+}
+
+// Value of column by name, nil if there is no such column
+func (r Row) Get(name string) interface{} {
+    value, _ := r.Lookup(name)
+    return value
+}
+
+// Convert row to map keyed by column alias.
+// Values of duplicate columns overwrite each other.
+func (r Row) Map() QueryResultRow {
+    row := QueryResultRow{}
+    for i, column := range r.meta.columns {
+        row[column.Name] = r.values[i]
+    }
+    return row
+}
@@ -0,0 +1,300 @@
+package mariadb
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Result set iterator. Rows are decoded one by one as they arrive,
+// so memory doesn't grow with result size.
+// Rows must be closed, connection can't serve other commands until
+// whole result is read.
+//
+//	rows, err := client.QueryRows("SELECT id, number FROM numbers")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer rows.Close()
+//	for rows.Next() {
+//	    var id, number int
+//	    if err := rows.Scan(&id, &number); err != nil {
+//	        log.Fatal(err)
+//	    }
+//	}
+//	if err := rows.Err(); err != nil {
+//	    log.Fatal(err)
+//	}
+type Rows struct {
+    ctx context.Context
+    q chan queuePacket
+    columns []tableColumn
+    meta *rowMeta
+    values []interface{}
+    err error
+    strict bool
+    done bool
+    closed bool
+    // result of statement without result set
+    result Result
+}
+
+// Run query and return iterator over its result set
+func (c *Connection) QueryRows(query string, args ...interface{}) (*Rows, error) {
+    return c.QueryRowsContext(context.Background(), query, args...)
+}
+
+// Run query and return iterator over its result set. When context is done
+// before result is read query is killed and Err returns context error.
+// Arguments are interpolated into ? placeholders on client side.
+func (c *Connection) QueryRowsContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+    return c.queryRows(ctx, c.communicateContext, query, args)
+}
+
+// Send query with given function and read response header
+func (c *Connection) queryRows(ctx context.Context, communicate func(context.Context, *Packet) chan queuePacket, query string, args []interface{}) (*Rows, error) {
+    if len(args) > 0 {
+        var err error
+        query, err = c.interpolate(query, args)
+        if err != nil {
+            return nil, err
+        }
+    }
+    q := communicate(ctx, createQueryPacket(query))
+    rows, err := c.readRows(ctx, q)
+    if err != nil {
+        finishResponse(ctx, q)
+        return nil, err
+    }
+    return rows, nil
+}
+
+// Read response header. Statements without result set
+// return empty Rows with Result.
+func (c *Connection) readRows(ctx context.Context, q chan queuePacket) (*Rows, error) {
+    response, ok := next(ctx, q)
+    if !ok {
+        return nil, errUnexpectedEnd
+    }
+    if response.error != nil {
+        return nil, response.error
+    }
+
+    rows := &Rows{ctx: ctx, q: q, strict: c.config.StrictScan}
+    packet := response.packet
+    if packet.isOK() {
+        rows.result = newResult(parseOkPacket(packet, c.info.capabilities()))
+        c.setLastResult(rows.result)
+        rows.Close()
+        return rows, nil
+    }
+
+    packet.skip(4)
+    columnCount := packet.readUIntLengthEncoded()
+    columns, err := c.readColumns(ctx, q, columnCount)
+    if err != nil {
+        return nil, err
+    }
+    rows.columns = columns
+    rows.meta = newRowMeta(columns)
+    return rows, nil
+}
+
+// Names of result set columns
+func (r *Rows) Columns() []string {
+    names := make([]string, len(r.columns))
+    for i, column := range r.columns {
+        names[i] = column.name
+    }
+    return names
+}
+
+// Read next row. Returns false when there are no more rows or error
+// occurred. Rows are closed automatically after the last row.
+func (r *Rows) Next() bool {
+    if r.closed || r.done {
+        return false
+    }
+
+    response, ok := next(r.ctx, r.q)
+    if !ok {
+        response.error = errUnexpectedEnd
+    }
+    if response.error != nil {
+        r.err = response.error
+        r.Close()
+        return false
+    }
+
+    packet := response.packet
+    if packet.isEOF() {
+        r.done = true
+        r.Close()
+        return false
+    }
+
+    r.values, r.err = readTextRow(packet, r.columns)
+    if r.err != nil {
+        r.Close()
+        return false
+    }
+    return true
+}
+
+// Copy values of current row into dest. Number of dest values
+// must be equal to number of columns.
+func (r *Rows) Scan(dest ...interface{}) error {
+    if r.values == nil {
+        return fmt.Errorf("Scan called without calling Next")
+    }
+    if len(dest) != len(r.values) {
+        return fmt.Errorf("expected %d destination arguments in Scan, not %d", len(r.values), len(dest))
+    }
+    for i, value := range r.values {
+        err := convertAssign(dest[i], value)
+        if err != nil {
+            return fmt.Errorf("Scan error on column %d '%s': %w", i, r.columns[i].name, err)
+        }
+    }
+    return nil
+}
+
+// Metadata of result set columns
+func (r *Rows) ColumnTypes() []ColumnType {
+    return columnTypes(r.columns)
+}
+
+// Current row. Row keeps column order, use Row.Map to get
+// QueryResultRow keyed by column name.
+func (r *Rows) Row() Row {
+    return Row{meta: r.meta, values: r.values}
+}
+
+// Read all remaining rows and close Rows
+func (r *Rows) All() ([]Row, error) {
+    defer r.Close()
+    result := []Row{}
+    for r.Next() {
+        result = append(result, r.Row())
+    }
+    if r.err != nil {
+        return nil, r.err
+    }
+    return result, nil
+}
+
+// Error occurred during iteration
+func (r *Rows) Err() error {
+    return r.err
+}
+
+// Affected rows, insert id and warnings of statement
+// which doesn't return result set
+func (r *Rows) Result() Result {
+    return r.result
+}
+
+// Stop iteration. Remaining rows are read and discarded,
+// so connection stays in sync.
+func (r *Rows) Close() error {
+    if r.closed {
+        return nil
+    }
+    r.closed = true
+    finishResponse(r.ctx, r.q)
+    return nil
+}
+
+// Decode row of text protocol.
+// See https://mariadb.com/kb/en/resultset-row/#text-resultset-row
+func readTextRow(packet *Packet, columns []tableColumn) ([]interface{}, error) {
+    packet.skip(4)
+    values := make([]interface{}, len(columns))
+    for i, column := range columns {
+        str, isNULL := packet.readStringLengthEncodedNULLABLE()
+        if isNULL {
+            continue
+        }
+        value, err := decodeTextValue(column, str)
+        if err != nil {
+            return nil, fmt.Errorf("column '%s': %w", column.name, err)
+        }
+        values[i] = value
+    }
+    packet.resetPos()
+    return values, nil
+}
+
+// Integers are decoded as int (uint64 for unsigned BIGINT), FLOAT as float32,
+// DOUBLE as float64, DATE/DATETIME/TIMESTAMP as time.Time in UTC,
+// TIME as time.Duration, DECIMAL as string, binary strings as []byte and other
+// types as string converted from column charset to UTF-8.
+func decodeTextValue(column tableColumn, str string) (interface{}, error) {
+    switch column.kind {
+    case MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT, MYSQL_TYPE_LONG, MYSQL_TYPE_INT24, MYSQL_TYPE_YEAR:
+        return strconv.Atoi(str)
+    case MYSQL_TYPE_LONGLONG:
+        if column.flag & FIELD_FLAG_UNSIGNED != 0 {
+            return strconv.ParseUint(str, 10, 64)
+        }
+        return strconv.Atoi(str)
+    case MYSQL_TYPE_FLOAT:
+        f, err := strconv.ParseFloat(str, 32)
+        return float32(f), err
+    case MYSQL_TYPE_DOUBLE:
+        return strconv.ParseFloat(str, 64)
+    case MYSQL_TYPE_DATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP:
+        return parseDateTime(str)
+    case MYSQL_TYPE_TIME:
+        return parseTime(str)
+    case MYSQL_TYPE_DECIMAL, MYSQL_TYPE_NEWDECIMAL:
+        return str, nil
+    }
+    if column.charset == binaryCharset {
+        return []byte(str), nil
+    }
+    return decodeString(column.charset, str), nil
+}
+
+// Parse DATE and DATETIME values. Zero dates are returned as zero time.
+func parseDateTime(str string) (time.Time, error) {
+    if strings.HasPrefix(str, "0000-00-00") {
+        return time.Time{}, nil
+    }
+    layout := "2006-01-02"
+    if len(str) > 10 {
+        layout = "2006-01-02 15:04:05.999999"
+    }
+    return time.ParseInLocation(layout, str, time.UTC)
+}
+
+// Parse TIME value in format [-]HHH:MM:SS[.ffffff]
+func parseTime(str string) (time.Duration, error) {
+    negative := strings.HasPrefix(str, "-")
+    str = strings.TrimPrefix(str, "-")
+    parts := strings.SplitN(str, ":", 3)
+    if len(parts) != 3 {
+        return 0, fmt.Errorf("invalid TIME value '%s'", str)
+    }
+    hours, err := strconv.Atoi(parts[0])
+    if err != nil {
+        return 0, err
+    }
+    minutes, err := strconv.Atoi(parts[1])
+    if err != nil {
+        return 0, err
+    }
+    seconds, err := strconv.ParseFloat(parts[2], 64)
+    if err != nil {
+        return 0, err
+    }
+    d := time.Duration(hours) * time.Hour +
+        time.Duration(minutes) * time.Minute +
+        time.Duration(seconds * float64(time.Second))
+    if negative {
+        d = -d
+    }
+    return d, nil
+}
@@ -0,0 +1,257 @@
+package mariadb
+
+import (
+    "encoding/binary"
+    "io"
+    "net"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/vasflam/lab-mysql-connector/mariadb/capabilities"
+)
+
+// Handler of command received by test server, returned packets are sent
+// as response. Handler may close connection to simulate network failure.
+type testHandler func(conn net.Conn, cmd []byte) [][]byte
+
+// Server speaking enough of protocol to test connection without MariaDB
+type testServer struct {
+    ln net.Listener
+    handler testHandler
+    mu sync.Mutex
+    commands [][]byte
+    accepted int
+    conns []net.Conn
+    // Response to handshake instead of OK, connection is closed after it
+    authResponse []byte
+}
+
+func newTestServer(t *testing.T, handler testHandler) *testServer {
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    s := &testServer{ln: ln, handler: handler}
+    t.Cleanup(func() {
+        ln.Close()
+    })
+    go s.serve()
+    return s
+}
+
+func (s *testServer) config() Config {
+    return Config{Uri: s.ln.Addr().String(), Username: "test", Password: "test"}
+}
+
+// Number of accepted connections
+func (s *testServer) connections() int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.accepted
+}
+
+// Text of received COM_QUERY commands
+func (s *testServer) queries() []string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    queries := []string{}
+    for _, cmd := range s.commands {
+        if cmd[0] == COM_QUERY {
+            queries = append(queries, commandQuery(cmd))
+        }
+    }
+    return queries
+}
+
+// Answer handshake of following connections with packet
+func (s *testServer) setAuthResponse(packet []byte) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.authResponse = packet
+}
+
+// Close all accepted connections, like restarted server
+func (s *testServer) dropConnections() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for _, conn := range s.conns {
+        conn.Close()
+    }
+}
+
+// Number of received commands of type
+func (s *testServer) count(command byte) int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    n := 0
+    for _, cmd := range s.commands {
+        if cmd[0] == command {
+            n++
+        }
+    }
+    return n
+}
+
+func (s *testServer) serve() {
+    for {
+        conn, err := s.ln.Accept()
+        if err != nil {
+            return
+        }
+        s.mu.Lock()
+        s.accepted++
+        s.conns = append(s.conns, conn)
+        id := uint32(s.accepted)
+        s.mu.Unlock()
+        go s.handle(conn, id)
+    }
+}
+
+func (s *testServer) handle(conn net.Conn, id uint32) {
+    defer conn.Close()
+    caps := uint32(capabilities.MYSQL | capabilities.CONNECT_WITH_DB | capabilities.PROTOCOL_41 |
+        capabilities.TRANSACTIONS | capabilities.SECURE_CONNECTION | capabilities.MULTI_STATEMENTS |
+        capabilities.MULTI_RESULTS | capabilities.PLUGIN_AUTH | capabilities.PLUGIN_AUTH_LENENC_CLIENT_DATA |
+        capabilities.SESSION_TRACK | capabilities.DEPRECATE_EOF)
+    // See https://mariadb.com/kb/en/connection/#initial-handshake-packet
+    handshake := []byte{10}
+    handshake = append(handshake, "10.6.0-MariaDB\x00"...)
+    handshake = binary.LittleEndian.AppendUint32(handshake, id)
+    handshake = append(handshake, "12345678\x00"...)
+    handshake = binary.LittleEndian.AppendUint16(handshake, uint16(caps))
+    handshake = append(handshake, 33, byte(SERVER_STATUS_AUTOCOMMIT), 0)
+    handshake = binary.LittleEndian.AppendUint16(handshake, uint16(caps >> 16))
+    handshake = append(handshake, 21)
+    handshake = append(handshake, make([]byte, 10)...)
+    handshake = append(handshake, "abcdefghijkl\x00"...)
+    handshake = append(handshake, "mysql_native_password\x00"...)
+    writeTestPacket(conn, 0, handshake)
+    if _, err := readTestPacket(conn); err != nil {
+        return
+    }
+    s.mu.Lock()
+    auth := s.authResponse
+    s.mu.Unlock()
+    if auth != nil {
+        writeTestPacket(conn, 2, auth)
+        return
+    }
+    writeTestPacket(conn, 2, testOK(0, 0, SERVER_STATUS_AUTOCOMMIT))
+
+    for {
+        cmd, err := readTestPacket(conn)
+        if err != nil {
+            return
+        }
+        s.mu.Lock()
+        s.commands = append(s.commands, cmd)
+        s.mu.Unlock()
+        var response [][]byte
+        switch cmd[0] {
+        case COM_QUIT:
+            return
+        case COM_PING, COM_RESET_CONN:
+            response = [][]byte{testOK(0, 0, SERVER_STATUS_AUTOCOMMIT)}
+        default:
+            response = s.handler(conn, cmd)
+        }
+        for i, packet := range response {
+            writeTestPacket(conn, byte(i + 1), packet)
+        }
+    }
+}
+
+func writeTestPacket(conn net.Conn, seq byte, payload []byte) {
+    header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}
+    conn.Write(append(header, payload...))
+}
+
+func readTestPacket(conn net.Conn) ([]byte, error) {
+    header := make([]byte, 4)
+    if _, err := io.ReadFull(conn, header); err != nil {
+        return nil, err
+    }
+    payload := make([]byte, int(header[0]) | int(header[1]) << 8 | int(header[2]) << 16)
+    _, err := io.ReadFull(conn, payload)
+    return payload, err
+}
+
+// Query of COM_QUERY command
+func commandQuery(cmd []byte) string {
+    return strings.TrimSuffix(string(cmd[1:]), "\x00")
+}
+
+func lengthEncoded(n uint64) []byte {
+    switch {
+    case n < 0xfb:
+        return []byte{byte(n)}
+    case n < 1 << 16:
+        return []byte{0xfc, byte(n), byte(n >> 8)}
+    case n < 1 << 24:
+        return []byte{0xfd, byte(n), byte(n >> 8), byte(n >> 16)}
+    }
+    return binary.LittleEndian.AppendUint64([]byte{0xfe}, n)
+}
+
+func lengthEncodedString(s string) []byte {
+    return append(lengthEncoded(uint64(len(s))), s...)
+}
+
+func testOK(affectedRows, lastInsertId uint64, status uint16) []byte {
+    packet := []byte{0}
+    packet = append(packet, lengthEncoded(affectedRows)...)
+    packet = append(packet, lengthEncoded(lastInsertId)...)
+    packet = binary.LittleEndian.AppendUint16(packet, status)
+    return append(packet, 0, 0)
+}
+
+func testErr(code uint16, message string) []byte {
+    packet := binary.LittleEndian.AppendUint16([]byte{0xff}, code)
+    packet = append(packet, "#HY000"...)
+    return append(packet, message...)
+}
+
+// Definition of VARCHAR column
+func testColumn(name string) []byte {
+    column := []byte{}
+    for _, s := range []string{"def", "test", "t", "t", name, name} {
+        column = append(column, lengthEncodedString(s)...)
+    }
+    return append(column, 0x0c, 33, 0, 0, 1, 0, 0, MYSQL_TYPE_VAR_STRING, 0, 0, 0, 0, 0)
+}
+
+// Text result set with VARCHAR columns, nil values are NULL
+func testResultSet(status uint16, columns []string, rows ...[]interface{}) [][]byte {
+    packets := [][]byte{lengthEncoded(uint64(len(columns)))}
+    for _, name := range columns {
+        packets = append(packets, testColumn(name))
+    }
+    for _, row := range rows {
+        packet := []byte{}
+        for _, value := range row {
+            if value == nil {
+                packet = append(packet, 0xfb)
+            } else {
+                packet = append(packet, lengthEncodedString(value.(string))...)
+            }
+        }
+        packets = append(packets, packet)
+    }
+    // OK packet with EOF header, see DEPRECATE_EOF
+    eof := binary.LittleEndian.AppendUint16([]byte{0xfe, 0, 0}, status)
+    return append(packets, append(eof, 0, 0))
+}
+
+// Wait until condition is true or fail test after second
+func waitFor(t *testing.T, condition func() bool) {
+    t.Helper()
+    deadline := time.Now().Add(time.Second)
+    for !condition() {
+        if time.Now().After(deadline) {
+            t.Fatal("condition isn't met in time")
+        }
+        time.Sleep(time.Millisecond)
+    }
+}
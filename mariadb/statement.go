@@ -0,0 +1,431 @@
+package mariadb
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "math"
+    "sync"
+    "time"
+)
+
+// See https://mariadb.com/kb/en/com_stmt_execute/#flag
+const CURSOR_TYPE_NO_CURSOR = 0
+const CURSOR_TYPE_READ_ONLY = 1
+const CURSOR_TYPE_FOR_UPDATE = 2
+const CURSOR_TYPE_SCROLLABLE = 4
+
+// Size of chunk sent with single COM_STMT_SEND_LONG_DATA command.
+// Must be less than max_allowed_packet server variable.
+const LongDataChunkSize = 1 << 20
+
+// Prepared statement.
+// Parameters implementing io.Reader are streamed to server
+// with COM_STMT_SEND_LONG_DATA before execution.
+type Statement struct {
+    mu sync.Mutex
+    conn *Connection
+    id uint32
+    query string
+    // parameter names of statement prepared with PrepareNamed
+    names []string
+    params []tableColumn
+    columns []tableColumn
+    // connection generation statement was prepared in
+    generation uint64
+}
+
+// See https://mariadb.com/kb/en/com_stmt_prepare/
+func (c *Connection) Prepare(query string) (*Statement, error) {
+    stmt := &Statement{
+        conn: c,
+        query: query,
+    }
+    err := stmt.prepare()
+    if err != nil {
+        return nil, err
+    }
+    return stmt, nil
+}
+
+// Prepare statement on server, also after reconnect
+func (s *Statement) prepare() error {
+    generation := s.conn.generation.Load()
+    q := s.conn.communicate(createStmtPreparePacket(s.query))
+    defer drainResponse(q)
+    response := <- q
+    if response.error != nil {
+        return response.error
+    }
+    packet := response.packet
+    packet.skip(5)
+    id := packet.readUInt32()
+    columnCount := int(packet.readUInt16())
+    paramCount := int(packet.readUInt16())
+    packet.resetPos()
+
+    params, err := s.conn.readColumns(context.Background(), q, paramCount)
+    if err != nil {
+        return err
+    }
+    columns, err := s.conn.readColumns(context.Background(), q, columnCount)
+    if err != nil {
+        return err
+    }
+    s.id = id
+    s.generation = generation
+    s.params = params
+    s.columns = columns
+    return nil
+}
+
+// Number of placeholders in statement
+func (s *Statement) NumInput() int {
+    return len(s.params)
+}
+
+// Metadata of columns returned by statement
+func (s *Statement) ColumnTypes() []ColumnType {
+    return columnTypes(s.columns)
+}
+
+// Execute statement with given arguments.
+// See https://mariadb.com/kb/en/com_stmt_execute/
+func (s *Statement) Execute(args ...interface{}) (QueryResultRows, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    q, response, err := s.execute(CURSOR_TYPE_NO_CURSOR, args)
+    if err != nil {
+        return nil, err
+    }
+    defer drainResponse(q)
+    if response.error != nil {
+        return nil, response.error
+    }
+    packet := response.packet
+    if packet.isOK() {
+        s.conn.setLastResult(newResult(parseOkPacket(packet, s.conn.info.capabilities())))
+        return nil, nil
+    }
+
+    packet.skip(4)
+    columnCount := packet.readUIntLengthEncoded()
+    columns, err := s.conn.readColumns(context.Background(), q, columnCount)
+    if err != nil {
+        return nil, err
+    }
+    rows, _, err := s.readRows(q, columns)
+    return rows, err
+}
+
+// Execute statement which doesn't return rows, e.g. INSERT or UPDATE.
+// Rows returned by statement are discarded.
+func (s *Statement) Exec(args ...interface{}) (Result, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    q, response, err := s.execute(CURSOR_TYPE_NO_CURSOR, args)
+    if err != nil {
+        return Result{}, err
+    }
+    defer drainResponse(q)
+    if response.error != nil {
+        return Result{}, response.error
+    }
+    if !response.packet.isOK() {
+        return Result{}, nil
+    }
+    result := newResult(parseOkPacket(response.packet, s.conn.info.capabilities()))
+    s.conn.setLastResult(result)
+    return result, nil
+}
+
+// Send long data and COM_STMT_EXECUTE, returns first packet of response.
+// Statement lost on reconnect is prepared and executed again, unless its
+// long data was already read. Caller must hold s.mu.
+func (s *Statement) execute(flags uint8, args []interface{}) (chan queuePacket, queuePacket, error) {
+    streamed := false
+    for {
+        // statements are lost when connection is restored
+        if s.generation != s.conn.generation.Load() {
+            err := s.prepare()
+            if err != nil {
+                return nil, queuePacket{}, err
+            }
+        }
+        if len(args) != len(s.params) {
+            return nil, queuePacket{}, fmt.Errorf("statement expects %d arguments, got %d", len(s.params), len(args))
+        }
+
+        for i, arg := range args {
+            if r, ok := arg.(io.Reader); ok {
+                streamed = true
+                err := s.sendLongData(uint16(i), r)
+                if err != nil {
+                    s.reset()
+                    return nil, queuePacket{}, err
+                }
+            }
+        }
+
+        packet, err := createStmtExecutePacket(s.id, flags, args)
+        if err != nil {
+            s.reset()
+            return nil, queuePacket{}, err
+        }
+        q := s.communicate(packet)
+        response := <- q
+        if response.error == errStatementLost && !streamed {
+            // command wasn't sent
+            continue
+        }
+        return q, response, nil
+    }
+}
+
+// Sends command of statement to queue
+func (s *Statement) communicate(packet *Packet) chan queuePacket {
+    q := createQueuePacket(packet)
+    q.statement = true
+    q.generation = s.generation
+    return s.conn.enqueue(q)
+}
+
+// Read binary rows until EOF packet. Returns server status of EOF packet.
+func (s *Statement) readRows(q chan queuePacket, columns []tableColumn) (QueryResultRows, uint16, error) {
+    rows := QueryResultRows{}
+    meta := newRowMeta(columns)
+    for {
+        response, ok := <- q
+        if !ok {
+            return nil, 0, errUnexpectedEnd
+        }
+        if response.error != nil {
+            return nil, 0, response.error
+        }
+        packet := response.packet
+        if packet.isEOF() {
+            status := parseOkPacket(packet, s.conn.info.capabilities()).status
+            return rows, status, nil
+        }
+        values, err := readBinaryRow(packet, columns)
+        if err != nil {
+            return nil, 0, err
+        }
+        rows = append(rows, Row{meta: meta, values: values}.Map())
+    }
+}
+
+// Deallocate statement on server
+func (s *Statement) Close() error {
+    q := s.communicate(createStmtClosePacket(s.id))
+    for response := range q {
+        if response.error != nil {
+            return response.error
+        }
+    }
+    return nil
+}
+
+// Stream reader to server in chunks of LongDataChunkSize bytes.
+// See https://mariadb.com/kb/en/com_stmt_send_long_data/
+func (s *Statement) sendLongData(param uint16, r io.Reader) error {
+    buf := make([]byte, LongDataChunkSize)
+    sent := false
+    for {
+        n, err := io.ReadFull(r, buf)
+        if n > 0 || !sent {
+            q := s.communicate(createStmtSendLongDataPacket(s.id, param, buf[:n]))
+            for response := range q {
+                if response.error != nil {
+                    return response.error
+                }
+            }
+            sent = true
+        }
+        if err == io.EOF || err == io.ErrUnexpectedEOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+    }
+}
+
+// Discard long data sent for statement.
+// See https://mariadb.com/kb/en/com_stmt_reset/
+func (s *Statement) reset() {
+    drainResponse(s.communicate(createStmtResetPacket(s.id)))
+}
+
+// Decode row of binary protocol.
+// See https://mariadb.com/kb/en/resultset-row/#binary-resultset-row
+func readBinaryRow(packet *Packet, columns []tableColumn) ([]interface{}, error) {
+    defer packet.resetPos()
+    bitmapLength := (len(columns) + 7 + 2) / 8
+    if packet.length() < 5 + bitmapLength {
+        return nil, fmt.Errorf("malformed binary row: packet ends before NULL bitmap")
+    }
+    packet.skip(5)
+    nullBitmap := packet.readBytes(bitmapLength)
+    values := make([]interface{}, len(columns))
+    for i, column := range columns {
+        bit := i + 2
+        if nullBitmap[bit / 8] & (1 << (bit % 8)) != 0 {
+            continue
+        }
+        if length, ok := binaryValueLength(packet, column); !ok || length > packet.remaining() {
+            return nil, fmt.Errorf("malformed binary row: packet ends before value of column '%s'", column.name)
+        }
+        value, err := readBinaryValue(packet, column)
+        if err != nil {
+            return nil, err
+        }
+        values[i] = value
+    }
+    return values, nil
+}
+
+// Number of bytes value of column takes in binary row. Returns false
+// when packet ends before length of value.
+func binaryValueLength(packet *Packet, column tableColumn) (int, bool) {
+    switch column.kind {
+    case MYSQL_TYPE_NULL:
+        return 0, true
+    case MYSQL_TYPE_TINY:
+        return 1, true
+    case MYSQL_TYPE_SHORT, MYSQL_TYPE_YEAR:
+        return 2, true
+    case MYSQL_TYPE_INT24, MYSQL_TYPE_LONG, MYSQL_TYPE_FLOAT:
+        return 4, true
+    case MYSQL_TYPE_LONGLONG, MYSQL_TYPE_DOUBLE:
+        return 8, true
+    }
+
+    rest := packet.readBytesRest()
+    if len(rest) == 0 {
+        return 0, false
+    }
+    switch column.kind {
+    case MYSQL_TYPE_DATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_TIME:
+        return 1 + int(rest[0]), true
+    }
+    // length encoded string
+    // See https://mariadb.com/kb/en/protocol-data-types/#length-encoded-strings
+    var size, length uint64
+    switch rest[0] {
+    case 0xfb:
+        return 1, true
+    case 0xfc:
+        size = 3
+    case 0xfd:
+        size = 4
+    case 0xfe:
+        size = 9
+    default:
+        return 1 + int(rest[0]), true
+    }
+    if uint64(len(rest)) < size {
+        return 0, false
+    }
+    for i := size - 1; i > 0; i-- {
+        length = length << 8 | uint64(rest[i])
+    }
+    if length > uint64(len(rest)) {
+        return 0, false
+    }
+    return int(size + length), true
+}
+
+func readBinaryValue(packet *Packet, column tableColumn) (interface{}, error) {
+    unsigned := column.flag & FIELD_FLAG_UNSIGNED != 0
+    switch column.kind {
+    case MYSQL_TYPE_NULL:
+        return nil, nil
+    case MYSQL_TYPE_TINY:
+        if unsigned {
+            return int(packet.readUInt8()), nil
+        }
+        return int(packet.readInt8()), nil
+    case MYSQL_TYPE_SHORT, MYSQL_TYPE_YEAR:
+        if unsigned {
+            return int(packet.readUInt16()), nil
+        }
+        return int(packet.readInt16()), nil
+    case MYSQL_TYPE_INT24, MYSQL_TYPE_LONG:
+        if unsigned {
+            return int(packet.readUInt32()), nil
+        }
+        return int(packet.readInt32()), nil
+    case MYSQL_TYPE_LONGLONG:
+        if unsigned {
+            return packet.readUInt64(), nil
+        }
+        return int(packet.readInt64()), nil
+    case MYSQL_TYPE_FLOAT:
+        return math.Float32frombits(packet.readUInt32()), nil
+    case MYSQL_TYPE_DOUBLE:
+        return math.Float64frombits(packet.readUInt64()), nil
+    case MYSQL_TYPE_DATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP:
+        return readBinaryDateTime(packet), nil
+    case MYSQL_TYPE_TIME:
+        return readBinaryTime(packet), nil
+    }
+
+    value, isNULL := packet.readStringLengthEncodedNULLABLE()
+    if isNULL {
+        return nil, nil
+    }
+    if column.kind == MYSQL_TYPE_DECIMAL || column.kind == MYSQL_TYPE_NEWDECIMAL {
+        return value, nil
+    }
+    if column.charset == binaryCharset {
+        return []byte(value), nil
+    }
+    return decodeString(column.charset, value), nil
+}
+
+// See https://mariadb.com/kb/en/resultset-row/#timestamp-binary-encoding
+func readBinaryDateTime(packet *Packet) time.Time {
+    length := packet.readUInt8()
+    var year, month, day, hour, min, sec, micro int
+    if length >= 4 {
+        year = int(packet.readUInt16())
+        month = int(packet.readUInt8())
+        day = int(packet.readUInt8())
+    }
+    if length >= 7 {
+        hour = int(packet.readUInt8())
+        min = int(packet.readUInt8())
+        sec = int(packet.readUInt8())
+    }
+    if length >= 11 {
+        micro = int(packet.readUInt32())
+    }
+    if length == 0 {
+        return time.Time{}
+    }
+    return time.Date(year, time.Month(month), day, hour, min, sec, micro * 1000, time.UTC)
+}
+
+// See https://mariadb.com/kb/en/resultset-row/#time-binary-encoding
+func readBinaryTime(packet *Packet) time.Duration {
+    length := packet.readUInt8()
+    var d time.Duration
+    negative := false
+    if length >= 8 {
+        negative = packet.readUInt8() == 1
+        d += time.Duration(packet.readUInt32()) * 24 * time.Hour
+        d += time.Duration(packet.readUInt8()) * time.Hour
+        d += time.Duration(packet.readUInt8()) * time.Minute
+        d += time.Duration(packet.readUInt8()) * time.Second
+    }
+    if length >= 12 {
+        d += time.Duration(packet.readUInt32()) * time.Microsecond
+    }
+    if negative {
+        d = -d
+    }
+    return d
+}
@@ -0,0 +1,95 @@
+package mariadb
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestReadBinaryRow(t *testing.T) {
+    columns := []tableColumn{
+        {name: "id", kind: MYSQL_TYPE_LONG},
+        {name: "name", kind: MYSQL_TYPE_VAR_STRING, charset: 45},
+        {name: "created", kind: MYSQL_TYPE_DATETIME},
+    }
+    row := func(data ...byte) *Packet {
+        packet := &Packet{}
+        packet.writeHeader([]byte{0, 0, 0, 0})
+        packet.writeBytes(data)
+        packet.direction = incomingPacket
+        return packet
+    }
+    tests := []struct {
+        name string
+        packet *Packet
+        values []interface{}
+        err string
+    }{
+        {
+            name: "values",
+            packet: row(0, 0, 7, 0, 0, 0, 3, 'b', 'o', 'b', 4, 0xe7, 0x07, 5, 6),
+            values: []interface{}{7, "bob", time.Date(2023, 5, 6, 0, 0, 0, 0, time.UTC)},
+        },
+        {
+            name: "NULL values",
+            // bits of columns 1 and 2 are shifted by 2
+            packet: row(0, 0x18, 9, 0, 0, 0),
+            values: []interface{}{9, nil, nil},
+        },
+        {
+            name: "empty packet",
+            packet: row(),
+            err: "before NULL bitmap",
+        },
+        {
+            name: "missing NULL bitmap",
+            packet: row(0),
+            err: "before NULL bitmap",
+        },
+        {
+            name: "truncated integer",
+            packet: row(0, 0, 7, 0),
+            err: "column 'id'",
+        },
+        {
+            name: "missing string",
+            packet: row(0, 0, 7, 0, 0, 0),
+            err: "column 'name'",
+        },
+        {
+            name: "string longer than packet",
+            packet: row(0, 0, 7, 0, 0, 0, 10, 'b', 'o', 'b'),
+            err: "column 'name'",
+        },
+        {
+            name: "truncated string length",
+            packet: row(0, 0, 7, 0, 0, 0, 0xfd, 1),
+            err: "column 'name'",
+        },
+        {
+            name: "huge string length",
+            packet: row(0, 0, 7, 0, 0, 0, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff),
+            err: "column 'name'",
+        },
+        {
+            name: "truncated date",
+            packet: row(0, 0, 7, 0, 0, 0, 0, 11, 0xe7, 0x07),
+            err: "column 'created'",
+        },
+    }
+    for _, test := range tests {
+        values, err := readBinaryRow(test.packet, columns)
+        if test.err != "" {
+            if err == nil || !strings.Contains(err.Error(), test.err) {
+                t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: %v", test.name, err)
+        } else if !reflect.DeepEqual(values, test.values) {
+            t.Errorf("%s: expected %v, got %v", test.name, test.values, values)
+        }
+    }
+}
@@ -0,0 +1,216 @@
+package mariadb
+
+import (
+    "context"
+    "fmt"
+    "reflect"
+    "strings"
+    "sync"
+)
+
+// Struct fields by column name. Fields are addressed by index path,
+// so fields of embedded structs can be set.
+type structFields struct {
+    names map[string][]int
+    lower map[string][]int
+}
+
+// Field mappings cached per struct type
+var structFieldsCache sync.Map
+
+// Fields are matched by `db:"name"` tag or by field name. Fields tagged
+// with `db:"-"` and unexported fields are skipped. Fields of embedded structs
+// are promoted unless embedded struct has db tag, ambiguous names are skipped.
+func getStructFields(t reflect.Type) *structFields {
+    if fields, ok := structFieldsCache.Load(t); ok {
+        return fields.(*structFields)
+    }
+    fields := &structFields{
+        names: map[string][]int{},
+        lower: map[string][]int{},
+    }
+    collectStructFields(fields, t)
+    actual, _ := structFieldsCache.LoadOrStore(t, fields)
+    return actual.(*structFields)
+}
+
+// Walk fields level by level like Go promotes fields of embedded structs:
+// shallower fields hide deeper ones, fields with same name at same depth
+// are ambiguous and aren't mapped.
+func collectStructFields(fields *structFields, t reflect.Type) {
+    type embedded struct {
+        t reflect.Type
+        index []int
+    }
+    // lowercased names of shallower fields
+    hidden := map[string]bool{}
+    visited := map[reflect.Type]bool{}
+    level := []embedded{{t, nil}}
+
+    for len(level) > 0 {
+        var next []embedded
+        names := map[string][][]int{}
+        lower := map[string][][]int{}
+        for _, e := range level {
+            // struct embedded at shallower depth hides its copies
+            if visited[e.t] {
+                continue
+            }
+            for i := 0; i < e.t.NumField(); i++ {
+                field := e.t.Field(i)
+                tag, hasTag := field.Tag.Lookup("db")
+                if tag == "-" {
+                    continue
+                }
+                path := append(append([]int{}, e.index...), i)
+
+                if field.Anonymous && !hasTag {
+                    ft := field.Type
+                    if ft.Kind() == reflect.Ptr {
+                        // Pointer to unexported struct can't be allocated
+                        if !field.IsExported() {
+                            continue
+                        }
+                        ft = ft.Elem()
+                    }
+                    if ft.Kind() == reflect.Struct {
+                        next = append(next, embedded{ft, path})
+                        continue
+                    }
+                }
+                if !field.IsExported() {
+                    continue
+                }
+
+                name := tag
+                if name == "" {
+                    name = field.Name
+                }
+                names[name] = append(names[name], path)
+                lower[strings.ToLower(name)] = append(lower[strings.ToLower(name)], path)
+            }
+        }
+        for _, e := range level {
+            visited[e.t] = true
+        }
+        for name, paths := range names {
+            if len(paths) == 1 && !hidden[strings.ToLower(name)] {
+                fields.names[name] = paths[0]
+            }
+        }
+        for name, paths := range lower {
+            if len(paths) == 1 && !hidden[name] {
+                fields.lower[name] = paths[0]
+            }
+        }
+        for name := range lower {
+            hidden[name] = true
+        }
+        level = next
+    }
+}
+
+// Find field for column. Exact match is preferred
+// over case-insensitive one.
+func (f *structFields) find(name string) ([]int, bool) {
+    if path, ok := f.names[name]; ok {
+        return path, true
+    }
+    path, ok := f.lower[strings.ToLower(name)]
+    return path, ok
+}
+
+// Get field by index path allocating nil embedded struct pointers
+func fieldByIndex(v reflect.Value, path []int) reflect.Value {
+    for i, x := range path {
+        if i > 0 && v.Kind() == reflect.Ptr {
+            if v.IsNil() {
+                v.Set(reflect.New(v.Type().Elem()))
+            }
+            v = v.Elem()
+        }
+        v = v.Field(x)
+    }
+    return v
+}
+
+// Copy current row into struct pointed by dest. Columns are matched to fields
+// by `db` tag or field name, use pointer fields for nullable columns.
+// Columns without matching field are skipped, unless Config.StrictScan is set.
+func (r *Rows) ScanStruct(dest interface{}) error {
+    if r.values == nil {
+        return fmt.Errorf("ScanStruct called without calling Next")
+    }
+    v := reflect.ValueOf(dest)
+    if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+        return fmt.Errorf("ScanStruct expects pointer to struct, got %T", dest)
+    }
+    v = v.Elem()
+    fields := getStructFields(v.Type())
+
+    for i, column := range r.columns {
+        path, ok := fields.find(column.name)
+        if !ok {
+            if r.strict {
+                return fmt.Errorf("column '%s' has no matching field in %s", column.name, v.Type())
+            }
+            continue
+        }
+        field := fieldByIndex(v, path)
+        err := convertAssign(field.Addr().Interface(), r.values[i])
+        if err != nil {
+            return fmt.Errorf("ScanStruct error on column '%s': %w", column.name, err)
+        }
+    }
+    return nil
+}
+
+// Run query and store rows into dest, which must be pointer to slice
+// of structs or pointers to structs.
+//
+//	var users []User
+//	err := client.QueryInto(&users, "SELECT id, name FROM users")
+func (c *Connection) QueryInto(dest interface{}, query string, args ...interface{}) error {
+    return c.QueryIntoContext(context.Background(), dest, query, args...)
+}
+
+func (c *Connection) QueryIntoContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+    slice := reflect.ValueOf(dest)
+    if slice.Kind() != reflect.Ptr || slice.IsNil() || slice.Elem().Kind() != reflect.Slice {
+        return fmt.Errorf("QueryInto expects pointer to slice, got %T", dest)
+    }
+    slice = slice.Elem()
+    elemType := slice.Type().Elem()
+    isPtr := elemType.Kind() == reflect.Ptr
+    if isPtr {
+        elemType = elemType.Elem()
+    }
+    if elemType.Kind() != reflect.Struct {
+        return fmt.Errorf("QueryInto expects slice of structs, got %T", dest)
+    }
+
+    rows, err := c.QueryRowsContext(ctx, query, args...)
+    if err != nil {
+        return err
+    }
+    defer rows.Close()
+
+    result := reflect.MakeSlice(slice.Type(), 0, 0)
+    for rows.Next() {
+        elem := reflect.New(elemType)
+        err := rows.ScanStruct(elem.Interface())
+        if err != nil {
+            return err
+        }
+        if isPtr {
+            result = reflect.Append(result, elem)
+        } else {
+            result = reflect.Append(result, elem.Elem())
+        }
+    }
+    if rows.Err() != nil {
+        return rows.Err()
+    }
+    slice.Set(result)
+    return nil
+}
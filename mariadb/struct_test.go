@@ -0,0 +1,155 @@
+package mariadb
+
+import (
+    "context"
+    "net"
+    "reflect"
+    "testing"
+)
+
+type testBase struct {
+    Id string
+    Name string
+    Created string `db:"created_at"`
+}
+
+type testAudit struct {
+    Name string
+    Created string `db:"created_at"`
+}
+
+type testDeep struct {
+    Note string
+}
+
+type testMiddle struct {
+    *testDeep
+    *Labeled
+    Title string
+}
+
+// pointers to unexported embedded structs are skipped, so these are exported
+type Labeled struct {
+    *Marked
+    Label string
+}
+
+type Marked struct {
+    Mark string
+}
+
+type testNode struct {
+    *testNode
+    Value string
+}
+
+type testShadowed struct {
+    testBase
+    Name string `db:"name"`
+}
+
+type testSameDepth struct {
+    testBase
+    testAudit
+}
+
+type testCaseConflict struct {
+    ID string
+    Id string
+}
+
+type testNested struct {
+    testMiddle
+    Skipped string `db:"-"`
+    hidden string
+}
+
+func TestStructFields(t *testing.T) {
+    tests := []struct {
+        name string
+        value interface{}
+        fields map[string][]int
+        missing []string
+    }{
+        {
+            name: "shallower field hides embedded one",
+            value: testShadowed{},
+            fields: map[string][]int{"name": {1}, "Name": {1}, "Id": {0, 0}, "created_at": {0, 2}},
+        },
+        {
+            name: "same depth names are ambiguous",
+            value: testSameDepth{},
+            fields: map[string][]int{"Id": {0, 0}},
+            missing: []string{"Name", "name", "created_at"},
+        },
+        {
+            name: "names differing by case are ambiguous case-insensitively",
+            value: testCaseConflict{},
+            fields: map[string][]int{"ID": {0}, "Id": {1}},
+            missing: []string{"id"},
+        },
+        {
+            name: "nested pointers",
+            value: testNested{},
+            fields: map[string][]int{"Title": {0, 2}, "Label": {0, 1, 1}, "mark": {0, 1, 0, 0}},
+            missing: []string{"Note", "Skipped", "hidden"},
+        },
+        {
+            name: "recursive embedding",
+            value: testNode{},
+            fields: map[string][]int{"Value": {1}},
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            fields := getStructFields(reflect.TypeOf(test.value))
+            for column, expected := range test.fields {
+                path, ok := fields.find(column)
+                if !ok || !reflect.DeepEqual(path, expected) {
+                    t.Errorf("column %s: expected field %v, got %v %v", column, expected, path, ok)
+                }
+            }
+            for _, column := range test.missing {
+                if path, ok := fields.find(column); ok {
+                    t.Errorf("column %s: expected no field, got %v", column, path)
+                }
+            }
+        })
+    }
+}
+
+func TestQueryIntoNestedPointers(t *testing.T) {
+    s := newTestServer(t, func(conn net.Conn, cmd []byte) [][]byte {
+        return testResultSet(SERVER_STATUS_AUTOCOMMIT, []string{"title", "label", "mark", "name"},
+            []interface{}{"first", "a", "b", "x"},
+            []interface{}{"second", "c", "d", "y"},
+        )
+    })
+    conn, err := Connect(s.config(), context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+
+    type row struct {
+        testNested
+        testSameDepth
+    }
+    var rows []*row
+    if err := conn.QueryInto(&rows, "SELECT"); err != nil {
+        t.Fatal(err)
+    }
+    if len(rows) != 2 {
+        t.Fatalf("expected 2 rows, got %d", len(rows))
+    }
+    for i, expected := range [][]string{{"first", "a", "b"}, {"second", "c", "d"}} {
+        r := rows[i]
+        if r.Title != expected[0] || r.Labeled == nil || r.Label != expected[1] || r.Marked == nil || r.Mark != expected[2] {
+            t.Errorf("row %d: unexpected nested fields %+v", i, r.testMiddle)
+        }
+    }
+    // ambiguous name column is skipped
+    if rows[0].testSameDepth.testBase.Name != "" || rows[0].testSameDepth.testAudit.Name != "" {
+        t.Errorf("ambiguous column is scanned into %+v", rows[0].testSameDepth)
+    }
+}
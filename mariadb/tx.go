@@ -0,0 +1,312 @@
+package mariadb
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "runtime"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Transaction isolation level.
+// See https://mariadb.com/kb/en/set-transaction/#isolation-levels
+type IsolationLevel string
+
+const ISOLATION_DEFAULT = IsolationLevel("")
+const ISOLATION_READ_UNCOMMITTED = IsolationLevel("READ UNCOMMITTED")
+const ISOLATION_READ_COMMITTED = IsolationLevel("READ COMMITTED")
+const ISOLATION_REPEATABLE_READ = IsolationLevel("REPEATABLE READ")
+const ISOLATION_SERIALIZABLE = IsolationLevel("SERIALIZABLE")
+
+// See https://mariadb.com/kb/en/mariadb-error-codes/
+const ER_LOCK_DEADLOCK = 1213
+
+// Number of times RunInTx repeats transaction rolled back by deadlock
+const TxDeadlockRetries = 3
+
+var ErrTxDone = fmt.Errorf("transaction has already been committed or rolled back")
+
+// Returned for command sent to connection, not to Tx, by goroutine which
+// started transaction. Command would wait for transaction to finish forever.
+var ErrTxActive = fmt.Errorf("connection is held by transaction of this goroutine, send commands with Tx")
+
+// Options of transaction.
+// See https://mariadb.com/kb/en/start-transaction/
+type TxOptions struct {
+    // Isolation level of this transaction, server default when empty
+    Isolation IsolationLevel
+    ReadOnly bool
+    // Start consistent read of InnoDB tables
+    ConsistentSnapshot bool
+}
+
+// Transaction holding connection exclusively. Commands sent to connection
+// from other goroutines wait until transaction is committed or rolled back,
+// goroutine which started transaction gets ErrTxActive for them. Statements
+// prepared on connection can't be used inside of transaction.
+// Rows must be closed before next command.
+type Tx struct {
+    *txSession
+}
+
+// Commands of connection held exclusively by transaction
+type txSession struct {
+    mu sync.Mutex
+    conn *Connection
+    queue chan queuePacket
+    done bool
+    // goroutine which acquired session
+    goroutine uint64
+}
+
+// Start transaction.
+//
+//	tx, err := client.Begin(ctx, mariadb.TxOptions{Isolation: mariadb.ISOLATION_SERIALIZABLE})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer tx.Rollback()
+//	_, err = tx.ExecContext(ctx, "UPDATE numbers SET number = number + 1")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	err = tx.Commit()
+func (c *Connection) Begin(ctx context.Context, options TxOptions) (*Tx, error) {
+    query, err := options.startQuery()
+    if err != nil {
+        return nil, err
+    }
+
+    session, err := c.acquireSession(ctx)
+    if err != nil {
+        return nil, err
+    }
+    tx := &Tx{session}
+
+    if options.Isolation != ISOLATION_DEFAULT {
+        // applies to next transaction only
+        _, err = tx.ExecContext(ctx, "SET TRANSACTION ISOLATION LEVEL " + string(options.Isolation))
+        if err == nil {
+            _, err = tx.ExecContext(ctx, query)
+        }
+    } else {
+        _, err = tx.ExecContext(ctx, query)
+    }
+    if err != nil {
+        tx.release()
+        return nil, err
+    }
+    return tx, nil
+}
+
+// Take connection exclusively, commands sent to connection wait
+// until session is released
+func (c *Connection) acquireSession(ctx context.Context) (*txSession, error) {
+    session := &txSession{
+        conn: c,
+        queue: make(chan queuePacket),
+    }
+    q := createQueuePacket(nil)
+    q.ctx = ctx
+    q.session = session.queue
+    // channel is closed without reply when session is granted
+    select {
+    case response, ok := <- c.enqueue(q):
+        if ok {
+            return nil, response.error
+        }
+        session.goroutine = goroutineId()
+        c.txGoroutine.Store(session.goroutine)
+        return session, nil
+    case <-ctx.Done():
+        // session may be granted after context is done
+        go func() {
+            if _, ok := <- q.c; !ok {
+                close(session.queue)
+            }
+        }()
+        return nil, ctx.Err()
+    }
+}
+
+// See https://mariadb.com/kb/en/start-transaction/
+func (o TxOptions) startQuery() (string, error) {
+    switch o.Isolation {
+    case ISOLATION_DEFAULT, ISOLATION_READ_UNCOMMITTED, ISOLATION_READ_COMMITTED,
+        ISOLATION_REPEATABLE_READ, ISOLATION_SERIALIZABLE:
+    default:
+        return "", fmt.Errorf("unknown isolation level '%s'", o.Isolation)
+    }
+
+    characteristics := []string{}
+    if o.ConsistentSnapshot {
+        characteristics = append(characteristics, "WITH CONSISTENT SNAPSHOT")
+    }
+    if o.ReadOnly {
+        characteristics = append(characteristics, "READ ONLY")
+    }
+    query := "START TRANSACTION"
+    if len(characteristics) > 0 {
+        query += " " + strings.Join(characteristics, ", ")
+    }
+    return query, nil
+}
+
+// Run fn inside of transaction. Transaction is committed when fn returns nil
+// and rolled back otherwise. Transaction rolled back by deadlock is repeated
+// up to TxDeadlockRetries times, so fn must not have other side effects.
+func (c *Connection) RunInTx(ctx context.Context, options TxOptions, fn func(tx *Tx) error) error {
+    for attempt := 0; ; attempt++ {
+        err := c.runInTx(ctx, options, fn)
+        if !isDeadlock(err) || attempt >= TxDeadlockRetries {
+            return err
+        }
+        select {
+        case <-time.After(time.Duration(attempt + 1) * 10 * time.Millisecond):
+        case <-ctx.Done():
+            return ctx.Err()
+        }
+    }
+}
+
+func (c *Connection) runInTx(ctx context.Context, options TxOptions, fn func(tx *Tx) error) error {
+    tx, err := c.Begin(ctx, options)
+    if err != nil {
+        return err
+    }
+    err = fn(tx)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    return tx.Commit()
+}
+
+func isDeadlock(err error) bool {
+    var mysqlErr *MysqlError
+    return errors.As(err, &mysqlErr) && mysqlErr.Code == ER_LOCK_DEADLOCK
+}
+
+// Sends command to transaction session
+func (s *txSession) communicateContext(ctx context.Context, packet *Packet) chan queuePacket {
+    q := createQueuePacket(packet)
+    q.ctx = ctx
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.done {
+        q.c <- createQueuePacketError(ErrTxDone)
+        close(q.c)
+        return q.c
+    }
+    select {
+    case s.queue <- q:
+    case <-s.conn.ctx.Done():
+        q.c <- createQueuePacketError(s.conn.closedError())
+        close(q.c)
+    }
+    return q.c
+}
+
+// Run query inside of transaction, see Connection.QueryRowsContext
+func (s *txSession) QueryRowsContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+    return s.conn.queryRows(ctx, s.communicateContext, query, args)
+}
+
+func (s *txSession) QueryRows(query string, args ...interface{}) (*Rows, error) {
+    return s.QueryRowsContext(context.Background(), query, args...)
+}
+
+// Run query inside of transaction, see Connection.QueryContext
+func (s *txSession) QueryContext(ctx context.Context, query string, args ...interface{}) (QueryResultRows, error) {
+    return readAll(s.QueryRowsContext(ctx, query, args...))
+}
+
+func (s *txSession) Query(query string, args ...interface{}) (QueryResultRows, error) {
+    return s.QueryContext(context.Background(), query, args...)
+}
+
+// Run statement inside of transaction, see Connection.ExecContext
+func (s *txSession) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+    return readResult(s.QueryRowsContext(ctx, query, args...))
+}
+
+func (s *txSession) Exec(query string, args ...interface{}) (Result, error) {
+    return s.ExecContext(context.Background(), query, args...)
+}
+
+// Commit transaction and release connection
+func (tx *Tx) Commit() error {
+    return tx.finish("COMMIT")
+}
+
+// Roll back transaction and release connection
+func (tx *Tx) Rollback() error {
+    return tx.finish("ROLLBACK")
+}
+
+// See https://mariadb.com/kb/en/savepoint/
+func (tx *Tx) Savepoint(name string) error {
+    _, err := tx.Exec("SAVEPOINT " + quoteIdentifier(name))
+    return err
+}
+
+// Roll back changes made after savepoint, transaction stays active
+func (tx *Tx) RollbackTo(name string) error {
+    _, err := tx.Exec("ROLLBACK TO SAVEPOINT " + quoteIdentifier(name))
+    return err
+}
+
+// Remove savepoint, changes made after it are kept
+func (tx *Tx) Release(name string) error {
+    _, err := tx.Exec("RELEASE SAVEPOINT " + quoteIdentifier(name))
+    return err
+}
+
+// Run final statement, connection is released even when it fails
+func (tx *Tx) finish(query string) error {
+    _, err := tx.Exec(query)
+    if err == ErrTxDone {
+        return err
+    }
+    tx.release()
+    return err
+}
+
+// Return connection to other goroutines
+func (s *txSession) release() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if !s.done {
+        s.done = true
+        // cleared before next session can be granted
+        s.conn.txGoroutine.CompareAndSwap(s.goroutine, 0)
+        close(s.queue)
+    }
+}
+
+// Command of this goroutine would wait for transaction which it started
+func (c *Connection) heldByCaller() bool {
+    holder := c.txGoroutine.Load()
+    return holder != 0 && holder == goroutineId()
+}
+
+// Id of current goroutine from header of its stack "goroutine 1 [running]:".
+// Used only to detect commands which would deadlock transaction.
+func goroutineId() uint64 {
+    buf := make([]byte, 64)
+    buf = bytes.TrimPrefix(buf[:runtime.Stack(buf, false)], []byte("goroutine "))
+    end := bytes.IndexByte(buf, ' ')
+    if end < 0 {
+        return 0
+    }
+    id, _ := strconv.ParseUint(string(buf[:end]), 10, 64)
+    return id
+}
+
+func quoteIdentifier(name string) string {
+    return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -0,0 +1,257 @@
+package mariadb
+
+import (
+    "context"
+    "net"
+    "reflect"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// Server tracking transaction status. Query DEADLOCK fails with deadlock
+// error while deadlocks counter is positive.
+type txServer struct {
+    *testServer
+    mu sync.Mutex
+    deadlocks int
+}
+
+func newTxServer(t *testing.T) *txServer {
+    s := &txServer{}
+    inTx := false
+    s.testServer = newTestServer(t, func(conn net.Conn, cmd []byte) [][]byte {
+        query := commandQuery(cmd)
+        switch {
+        case strings.HasPrefix(query, "START TRANSACTION"):
+            inTx = true
+        case query == "COMMIT" || query == "ROLLBACK":
+            inTx = false
+        case query == "DEADLOCK":
+            s.mu.Lock()
+            defer s.mu.Unlock()
+            if s.deadlocks > 0 {
+                s.deadlocks--
+                inTx = false
+                return [][]byte{testErr(ER_LOCK_DEADLOCK, "Deadlock found when trying to get lock")}
+            }
+        case strings.HasPrefix(query, "SELECT"):
+            return testResultSet(SERVER_STATUS_AUTOCOMMIT, []string{"q"}, []interface{}{query})
+        }
+        status := uint16(SERVER_STATUS_AUTOCOMMIT)
+        if inTx {
+            status |= SERVER_STATUS_IN_TRANS
+        }
+        return [][]byte{testOK(0, 0, status)}
+    })
+    return s
+}
+
+func (s *txServer) setDeadlocks(n int) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.deadlocks = n
+}
+
+func (s *txServer) connect(t *testing.T) *Connection {
+    conn, err := Connect(s.config(), context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(conn.Close)
+    return conn
+}
+
+func (s *txServer) checkQueries(t *testing.T, expected ...string) {
+    t.Helper()
+    if queries := s.queries(); !reflect.DeepEqual(queries, expected) {
+        t.Errorf("expected queries %q, got %q", expected, queries)
+    }
+}
+
+func TestTxCommit(t *testing.T) {
+    s := newTxServer(t)
+    conn := s.connect(t)
+
+    options := TxOptions{Isolation: ISOLATION_SERIALIZABLE, ReadOnly: true, ConsistentSnapshot: true}
+    tx, err := conn.Begin(context.Background(), options)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := tx.Exec("UPDATE t SET a = ?", 1); err != nil {
+        t.Fatal(err)
+    }
+    if conn.status.Load() & SERVER_STATUS_IN_TRANS == 0 {
+        t.Error("transaction isn't active")
+    }
+    if err := tx.Commit(); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := tx.Exec("UPDATE t SET a = 2"); err != ErrTxDone {
+        t.Errorf("expected ErrTxDone, got %v", err)
+    }
+    if err := tx.Commit(); err != ErrTxDone {
+        t.Errorf("expected ErrTxDone, got %v", err)
+    }
+    if err := tx.Rollback(); err != ErrTxDone {
+        t.Errorf("expected ErrTxDone, got %v", err)
+    }
+    s.checkQueries(t,
+        "SET TRANSACTION ISOLATION LEVEL SERIALIZABLE",
+        "START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY",
+        "UPDATE t SET a = 1",
+        "COMMIT",
+    )
+
+    if _, err := conn.Begin(context.Background(), TxOptions{Isolation: "SNAPSHOT"}); err == nil {
+        t.Error("expected error of unknown isolation level")
+    }
+}
+
+func TestTxRollbackAndSavepoints(t *testing.T) {
+    s := newTxServer(t)
+    conn := s.connect(t)
+
+    tx, err := conn.Begin(context.Background(), TxOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, step := range []func() error{
+        func() error { return tx.Savepoint("a`b") },
+        func() error { return tx.RollbackTo("a`b") },
+        func() error { return tx.Release("a`b") },
+        tx.Rollback,
+    } {
+        if err := step(); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := tx.Savepoint("c"); err != ErrTxDone {
+        t.Errorf("expected ErrTxDone, got %v", err)
+    }
+    s.checkQueries(t,
+        "START TRANSACTION",
+        "SAVEPOINT `a``b`",
+        "ROLLBACK TO SAVEPOINT `a``b`",
+        "RELEASE SAVEPOINT `a``b`",
+        "ROLLBACK",
+    )
+}
+
+func TestTxHoldsConnection(t *testing.T) {
+    s := newTxServer(t)
+    conn := s.connect(t)
+
+    tx, err := conn.Begin(context.Background(), TxOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    // command of other goroutine waits for transaction
+    other := goQuery(conn, "SELECT 2")
+    time.Sleep(20 * time.Millisecond)
+    if _, err := tx.Exec("UPDATE t SET a = 1"); err != nil {
+        t.Fatal(err)
+    }
+    if err := tx.Commit(); err != nil {
+        t.Fatal(err)
+    }
+    r := waitResult(t, other)
+    checkEcho(t, "SELECT 2", r.rows, r.err)
+    s.checkQueries(t, "START TRANSACTION", "UPDATE t SET a = 1", "COMMIT", "SELECT 2")
+}
+
+func TestTxCommandOfSameGoroutine(t *testing.T) {
+    s := newTxServer(t)
+    conn := s.connect(t)
+
+    err := conn.RunInTx(context.Background(), TxOptions{}, func(tx *Tx) error {
+        // these would wait for transaction forever
+        if _, err := conn.Query("SELECT 1"); err != ErrTxActive {
+            t.Errorf("Query: expected ErrTxActive, got %v", err)
+        }
+        if _, err := conn.Exec("UPDATE t SET a = 1"); err != ErrTxActive {
+            t.Errorf("Exec: expected ErrTxActive, got %v", err)
+        }
+        if _, err := conn.Prepare("SELECT ?"); err != ErrTxActive {
+            t.Errorf("Prepare: expected ErrTxActive, got %v", err)
+        }
+        if _, err := conn.Begin(context.Background(), TxOptions{}); err != ErrTxActive {
+            t.Errorf("Begin: expected ErrTxActive, got %v", err)
+        }
+        batch := &Batch{}
+        batch.Queue("SELECT 1")
+        if _, err := conn.SendBatch(context.Background(), batch); err != ErrTxActive {
+            t.Errorf("SendBatch: expected ErrTxActive, got %v", err)
+        }
+        if err := waitFuture(t, conn.QueryAsync(context.Background(), "SELECT 1")); err != ErrTxActive {
+            t.Errorf("QueryAsync: expected ErrTxActive, got %v", err)
+        }
+        _, err := tx.Exec("UPDATE t SET a = 2")
+        return err
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    // connection is free after transaction
+    rows, err := conn.Query("SELECT 3")
+    checkEcho(t, "SELECT 3", rows, err)
+    s.checkQueries(t, "START TRANSACTION", "UPDATE t SET a = 2", "COMMIT", "SELECT 3")
+}
+
+func TestRunInTx(t *testing.T) {
+    s := newTxServer(t)
+    conn := s.connect(t)
+
+    failure := &MysqlError{Code: 1062, Message: "Duplicate entry"}
+    err := conn.RunInTx(context.Background(), TxOptions{}, func(tx *Tx) error {
+        if _, err := tx.Exec("INSERT INTO t VALUES (1)"); err != nil {
+            return err
+        }
+        return failure
+    })
+    if err != failure {
+        t.Errorf("expected error of fn, got %v", err)
+    }
+    s.checkQueries(t, "START TRANSACTION", "INSERT INTO t VALUES (1)", "ROLLBACK")
+}
+
+func TestRunInTxDeadlockRetry(t *testing.T) {
+    s := newTxServer(t)
+    conn := s.connect(t)
+
+    s.setDeadlocks(TxDeadlockRetries)
+    attempts := 0
+    err := conn.RunInTx(context.Background(), TxOptions{}, func(tx *Tx) error {
+        attempts++
+        _, err := tx.Exec("DEADLOCK")
+        return err
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if attempts != TxDeadlockRetries + 1 {
+        t.Errorf("expected %d attempts, got %d", TxDeadlockRetries + 1, attempts)
+    }
+    expected := []string{}
+    for i := 0; i < TxDeadlockRetries; i++ {
+        expected = append(expected, "START TRANSACTION", "DEADLOCK", "ROLLBACK")
+    }
+    expected = append(expected, "START TRANSACTION", "DEADLOCK", "COMMIT")
+    s.checkQueries(t, expected...)
+
+    // deadlock error is returned when retries are exhausted
+    s.setDeadlocks(TxDeadlockRetries + 1)
+    attempts = 0
+    err = conn.RunInTx(context.Background(), TxOptions{}, func(tx *Tx) error {
+        attempts++
+        _, err := tx.Exec("DEADLOCK")
+        return err
+    })
+    if !isDeadlock(err) {
+        t.Errorf("expected deadlock error, got %v", err)
+    }
+    if attempts != TxDeadlockRetries + 1 {
+        t.Errorf("expected %d attempts, got %d", TxDeadlockRetries + 1, attempts)
+    }
+}
@@ -0,0 +1,312 @@
+package mariadb
+
+import (
+    "context"
+    "encoding/hex"
+    "fmt"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// States of XA transaction branch.
+// See https://mariadb.com/kb/en/xa-transactions/
+const XA_ACTIVE = 1
+const XA_IDLE = 2
+const XA_PREPARED = 3
+const XA_DONE = 4
+
+// Identifier of XA transaction branch
+type Xid struct {
+    FormatId int
+    // Global transaction identifier, up to 64 bytes
+    Gtrid string
+    // Branch qualifier, up to 64 bytes
+    Bqual string
+}
+
+func (x Xid) validate() error {
+    if len(x.Gtrid) == 0 || len(x.Gtrid) > 64 {
+        return fmt.Errorf("xid gtrid must be 1-64 bytes long")
+    }
+    if len(x.Bqual) > 64 {
+        return fmt.Errorf("xid bqual must be at most 64 bytes long")
+    }
+    return nil
+}
+
+// Xid as sql, e.g. X'6774726964',X'',1
+func (x Xid) String() string {
+    return fmt.Sprintf("X'%s',X'%s',%d", hex.EncodeToString([]byte(x.Gtrid)), hex.EncodeToString([]byte(x.Bqual)), x.FormatId)
+}
+
+// XA transaction branch holding connection exclusively, like Tx.
+// Statements are allowed only in active state.
+//
+//	xa, err := client.XAStart(ctx, xid)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer xa.Rollback()
+//	_, err = xa.Exec("UPDATE accounts SET balance = balance - 10 WHERE id = 1")
+//	err = xa.End()
+//	err = xa.Prepare()
+//	// prepare other branches, then
+//	err = xa.Commit(false)
+type XATx struct {
+    mu sync.Mutex
+    session *txSession
+    xid Xid
+    state int
+}
+
+// Start XA transaction branch.
+// See https://mariadb.com/kb/en/xa-transactions/#xa-start
+func (c *Connection) XAStart(ctx context.Context, xid Xid) (*XATx, error) {
+    err := xid.validate()
+    if err != nil {
+        return nil, err
+    }
+    session, err := c.acquireSession(ctx)
+    if err != nil {
+        return nil, err
+    }
+    _, err = session.ExecContext(ctx, "XA START " + xid.String())
+    if err != nil {
+        session.release()
+        return nil, err
+    }
+    return &XATx{session: session, xid: xid, state: XA_ACTIVE}, nil
+}
+
+// Identifier of branch
+func (x *XATx) Xid() Xid {
+    return x.xid
+}
+
+// Current state, one of XA_ACTIVE, XA_IDLE, XA_PREPARED and XA_DONE
+func (x *XATx) State() int {
+    x.mu.Lock()
+    defer x.mu.Unlock()
+    return x.state
+}
+
+func (x *XATx) checkActive() error {
+    x.mu.Lock()
+    defer x.mu.Unlock()
+    return x.expect("run statement", XA_ACTIVE)
+}
+
+// Return error when branch isn't in one of states. Caller must hold x.mu.
+func (x *XATx) expect(action string, states ...int) error {
+    for _, state := range states {
+        if x.state == state {
+            return nil
+        }
+    }
+    if x.state == XA_DONE {
+        return ErrTxDone
+    }
+    return fmt.Errorf("can't %s in %s state of XA transaction", action, xaStateName(x.state))
+}
+
+func xaStateName(state int) string {
+    switch state {
+    case XA_ACTIVE:
+        return "ACTIVE"
+    case XA_IDLE:
+        return "IDLE"
+    case XA_PREPARED:
+        return "PREPARED"
+    }
+    return "DONE"
+}
+
+// Run query inside of active branch, see Connection.QueryRowsContext
+func (x *XATx) QueryRowsContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+    if err := x.checkActive(); err != nil {
+        return nil, err
+    }
+    return x.session.QueryRowsContext(ctx, query, args...)
+}
+
+func (x *XATx) QueryRows(query string, args ...interface{}) (*Rows, error) {
+    return x.QueryRowsContext(context.Background(), query, args...)
+}
+
+// Run query inside of active branch, see Connection.QueryContext
+func (x *XATx) QueryContext(ctx context.Context, query string, args ...interface{}) (QueryResultRows, error) {
+    if err := x.checkActive(); err != nil {
+        return nil, err
+    }
+    return x.session.QueryContext(ctx, query, args...)
+}
+
+func (x *XATx) Query(query string, args ...interface{}) (QueryResultRows, error) {
+    return x.QueryContext(context.Background(), query, args...)
+}
+
+// Run statement inside of active branch, see Connection.ExecContext
+func (x *XATx) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+    if err := x.checkActive(); err != nil {
+        return Result{}, err
+    }
+    return x.session.ExecContext(ctx, query, args...)
+}
+
+func (x *XATx) Exec(query string, args ...interface{}) (Result, error) {
+    return x.ExecContext(context.Background(), query, args...)
+}
+
+// Finish work of active branch, moves it to idle state
+func (x *XATx) End() error {
+    x.mu.Lock()
+    defer x.mu.Unlock()
+    if err := x.expect("end", XA_ACTIVE); err != nil {
+        return err
+    }
+    return x.transit("XA END", XA_IDLE)
+}
+
+// Prepare idle branch for two-phase commit
+func (x *XATx) Prepare() error {
+    x.mu.Lock()
+    defer x.mu.Unlock()
+    if err := x.expect("prepare", XA_IDLE); err != nil {
+        return err
+    }
+    return x.transit("XA PREPARE", XA_PREPARED)
+}
+
+// Commit branch and release connection. One-phase commit is done
+// from idle state, two-phase commit from prepared state.
+func (x *XATx) Commit(onePhase bool) error {
+    x.mu.Lock()
+    defer x.mu.Unlock()
+    if onePhase {
+        if err := x.expect("commit one phase", XA_IDLE); err != nil {
+            return err
+        }
+        return x.finish("XA COMMIT", " ONE PHASE")
+    }
+    if err := x.expect("commit", XA_PREPARED); err != nil {
+        return err
+    }
+    return x.finish("XA COMMIT", "")
+}
+
+// Roll back branch and release connection. Active branch is ended first.
+func (x *XATx) Rollback() error {
+    x.mu.Lock()
+    defer x.mu.Unlock()
+    if err := x.expect("roll back", XA_ACTIVE, XA_IDLE, XA_PREPARED); err != nil {
+        return err
+    }
+    if x.state == XA_ACTIVE {
+        if err := x.transit("XA END", XA_IDLE); err != nil {
+            x.session.release()
+            x.state = XA_DONE
+            return err
+        }
+    }
+    return x.finish("XA ROLLBACK", "")
+}
+
+// Run XA statement and change state when it succeeds. Caller must hold x.mu.
+func (x *XATx) transit(statement string, state int) error {
+    _, err := x.session.Exec(statement + " " + x.xid.String())
+    if err != nil {
+        return err
+    }
+    x.state = state
+    return nil
+}
+
+// Run final XA statement, connection is released even when it fails.
+// Failed prepared branch can be found with XARecover. Caller must hold x.mu.
+func (x *XATx) finish(statement string, suffix string) error {
+    _, err := x.session.Exec(statement + " " + x.xid.String() + suffix)
+    x.session.release()
+    x.state = XA_DONE
+    return err
+}
+
+// Commit prepared branch left by other connection, e.g. found with XARecover
+func (c *Connection) XACommit(ctx context.Context, xid Xid) error {
+    if err := xid.validate(); err != nil {
+        return err
+    }
+    _, err := c.ExecContext(ctx, "XA COMMIT " + xid.String())
+    return err
+}
+
+// Roll back prepared branch left by other connection
+func (c *Connection) XARollback(ctx context.Context, xid Xid) error {
+    if err := xid.validate(); err != nil {
+        return err
+    }
+    _, err := c.ExecContext(ctx, "XA ROLLBACK " + xid.String())
+    return err
+}
+
+// List branches in prepared state. Xids are requested in SQL format,
+// so binary identifiers aren't changed by charset conversion.
+// See https://mariadb.com/kb/en/xa-transactions/#xa-recover
+func (c *Connection) XARecover(ctx context.Context) ([]Xid, error) {
+    rows, err := c.QueryRowsContext(ctx, "XA RECOVER FORMAT='SQL'")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    xids := []Xid{}
+    for rows.Next() {
+        var formatId, gtridLength, bqualLength int
+        var data string
+        err = rows.Scan(&formatId, &gtridLength, &bqualLength, &data)
+        if err != nil {
+            return nil, err
+        }
+        xid, err := parseXid(data)
+        if err != nil {
+            return nil, err
+        }
+        if xid.FormatId != formatId || len(xid.Gtrid) != gtridLength || len(xid.Bqual) != bqualLength {
+            return nil, fmt.Errorf("xid %s doesn't match its lengths in XA RECOVER result", data)
+        }
+        xids = append(xids, xid)
+    }
+    if rows.Err() != nil {
+        return nil, rows.Err()
+    }
+    return xids, nil
+}
+
+// Parse xid in SQL format, see Xid.String. Bqual may be omitted.
+func parseXid(s string) (Xid, error) {
+    invalid := fmt.Errorf("invalid xid %s in XA RECOVER result", s)
+    parts := strings.Split(s, ",")
+    if len(parts) != 2 && len(parts) != 3 {
+        return Xid{}, invalid
+    }
+    formatId, err := strconv.Atoi(parts[len(parts) - 1])
+    if err != nil {
+        return Xid{}, invalid
+    }
+    identifiers := []string{}
+    for _, part := range parts[:len(parts) - 1] {
+        if len(part) < 3 || part[0] != 'X' && part[0] != 'x' || part[1] != '\'' || part[len(part) - 1] != '\'' {
+            return Xid{}, invalid
+        }
+        identifier, err := hex.DecodeString(part[2:len(part) - 1])
+        if err != nil {
+            return Xid{}, invalid
+        }
+        identifiers = append(identifiers, string(identifier))
+    }
+    xid := Xid{FormatId: formatId, Gtrid: identifiers[0]}
+    if len(identifiers) == 2 {
+        xid.Bqual = identifiers[1]
+    }
+    return xid, nil
+}
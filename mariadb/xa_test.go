@@ -0,0 +1,49 @@
+package mariadb
+
+import (
+    "context"
+    "net"
+    "reflect"
+    "testing"
+)
+
+func TestXARecover(t *testing.T) {
+    binary := Xid{FormatId: 7, Gtrid: "\xff\x00\xc3(", Bqual: "b"}
+    s := newTestServer(t, func(conn net.Conn, cmd []byte) [][]byte {
+        if commandQuery(cmd) == "XA RECOVER FORMAT='SQL'" {
+            columns := []string{"formatID", "gtrid_length", "bqual_length", "data"}
+            return testResultSet(SERVER_STATUS_AUTOCOMMIT, columns,
+                []interface{}{"7", "4", "1", binary.String()},
+                []interface{}{"1", "5", "0", "X'6774726964',1"},
+            )
+        }
+        return [][]byte{testOK(0, 0, SERVER_STATUS_AUTOCOMMIT)}
+    })
+    conn, err := Connect(s.config(), context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+
+    xids, err := conn.XARecover(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    expected := []Xid{binary, {FormatId: 1, Gtrid: "gtrid"}}
+    if !reflect.DeepEqual(xids, expected) {
+        t.Errorf("xids %q, expected %q", xids, expected)
+    }
+}
+
+func TestParseXid(t *testing.T) {
+    xid := Xid{FormatId: 3, Gtrid: "g\x00", Bqual: "\xff"}
+    parsed, err := parseXid(xid.String())
+    if err != nil || parsed != xid {
+        t.Errorf("parsed %+v (%v), expected %+v", parsed, err, xid)
+    }
+    for _, data := range []string{"", "gtrid", "X'67',X'',X'',1", "X'6',1", "X'67',X'',a", "'67',1"} {
+        if _, err := parseXid(data); err == nil {
+            t.Errorf("expected error for %q", data)
+        }
+    }
+}